@@ -0,0 +1,402 @@
+package proj
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var TrustPath = path.Join(os.Getenv("HOME"), ".proj", "trusted.json")
+
+var ErrInvalidEnvFile = errors.New("Invalid env file, expected NAME=VALUE lines")
+
+// Trust maps the folders of trusted projects to a hash of the environment and hooks that were trusted, so
+// changing them (e.g. by pulling a new version of the project) needs them to be trusted again
+type Trust map[string]string
+
+// LoadTrust reads the trusted projects, a missing trust file means no project is trusted
+func LoadTrust() (trust Trust, err error) {
+	trust = make(Trust)
+	data, err := ioutil.ReadFile(TrustPath)
+	if os.IsNotExist(err) {
+		return trust, nil
+	} else if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(data, &trust)
+	return
+}
+
+func (trust Trust) Write() error {
+	data, err := json.MarshalIndent(trust, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(path.Dir(TrustPath), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(TrustPath, data, 0600)
+}
+
+// readActivationFile reads a file inside a project, along with its mode, a file that doesn't exist is empty
+func readActivationFile(folder, rel string) (string, error) {
+	file := filepath.Join(folder, rel)
+	info, err := os.Stat(file)
+	if os.IsNotExist(err) || (err == nil && info.IsDir()) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return info.Mode().String() + "\n" + string(data), nil
+}
+
+// hookFiles finds the files inside a project that are named in a command, e.g. scripts/setup.sh in
+// "./scripts/setup.sh --quiet"
+func hookFiles(folder, command string) (files []string) {
+	words := strings.FieldsFunc(command, func(r rune) bool {
+		return strings.ContainsRune(" \t\n;&|()<>\"'`", r)
+	})
+
+	for _, word := range words {
+		rel := filepath.Clean(word)
+		if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, "../") {
+			continue
+		}
+
+		if info, err := os.Stat(filepath.Join(folder, rel)); err == nil && info.Mode().IsRegular() {
+			files = append(files, rel)
+		}
+	}
+	return
+}
+
+// activationHash hashes everything in a project that can run code or change the environment when visiting it:
+// its metadata, its env files, the files in its Path folders and the files named in its hooks and tmux panes.
+// Files that hooks only use indirectly, like a Makefile run by make, aren't covered. The hash is empty if visiting
+// the project doesn't do anything that needs to be trusted.
+func activationHash(folder string, meta *Metadata) (hash string, err error) {
+	if len(meta.Env) == 0 && len(meta.EnvFiles) == 0 && len(meta.Path) == 0 &&
+		len(meta.OnEnter) == 0 && len(meta.OnExit) == 0 && len(meta.TmuxWindows) == 0 {
+		return "", nil
+	}
+
+	envFiles := make(map[string]string, len(meta.EnvFiles))
+	for _, file := range meta.EnvFiles {
+		data, err := ioutil.ReadFile(filepath.Join(folder, file))
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		envFiles[file] = string(data)
+	}
+
+	files := make(map[string]string)
+	for _, dir := range meta.Path {
+		finfo, err := ioutil.ReadDir(filepath.Join(folder, dir))
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+
+		for _, f := range finfo {
+			if f.IsDir() {
+				continue
+			}
+
+			rel := filepath.Join(dir, f.Name())
+			files[rel], err = readActivationFile(folder, rel)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	commands := append(append([]string{}, meta.OnEnter...), meta.OnExit...)
+	for _, window := range meta.TmuxWindows {
+		commands = append(commands, window.Panes...)
+	}
+
+	for _, command := range commands {
+		for _, rel := range hookFiles(folder, command) {
+			files[rel], err = readActivationFile(folder, rel)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	data, err := json.Marshal([]interface{}{meta.Env, envFiles, meta.Path, meta.OnEnter, meta.OnExit, meta.TmuxWindows, files})
+	if err != nil {
+		return
+	}
+
+	hashed := sha256.Sum256(data)
+	return hex.EncodeToString(hashed[:]), nil
+}
+
+// Trusted is true if visiting a project can apply its environment and run its hooks
+func (trust Trust) Trusted(folder string, meta *Metadata) (bool, error) {
+	hash, err := activationHash(folder, meta)
+	if err != nil || hash == "" {
+		return err == nil, err
+	}
+	return trust[folder] == hash, nil
+}
+
+// Allow trusts a project's current environment and hooks
+func (trust Trust) Allow(folder string, meta *Metadata) error {
+	hash, err := activationHash(folder, meta)
+	if err != nil {
+		return err
+	}
+
+	if hash == "" {
+		delete(trust, folder)
+	} else {
+		trust[folder] = hash
+	}
+	return nil
+}
+
+// Revoke stops trusting a project
+func (trust Trust) Revoke(folder string) {
+	delete(trust, folder)
+}
+
+// Move keeps trusting a project after it's moved to another folder
+func (trust Trust) Move(folder, newFolder string) {
+	if hash, exists := trust[folder]; exists {
+		delete(trust, folder)
+		trust[newFolder] = hash
+	}
+}
+
+// parseEnvFile reads a .env file, with NAME=VALUE lines, blank lines and # comments are skipped, a line can
+// start with "export", and values can be quoted
+func parseEnvFile(file string) (env map[string]string, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	env = make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = strings.TrimSpace(strings.TrimPrefix(text, "export "))
+
+		i := strings.IndexByte(text, '=')
+		if i <= 0 {
+			return nil, errors.WithMessage(ErrInvalidEnvFile, file+":"+strconv.Itoa(line))
+		}
+
+		name, value := strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:])
+		switch {
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				value = unquoted
+			} else {
+				value = value[1 : len(value)-1]
+			}
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		}
+		env[name] = value
+	}
+	return env, scanner.Err()
+}
+
+// Activation is what visiting a project does
+type Activation struct {
+	// Env are the variables set while visiting the project
+	Env map[string]string
+
+	// OnEnter and OnExit are shell commands run when the project is visited and left
+	OnEnter []string
+	OnExit  []string
+
+	// Untrusted is true if the project has an environment or hooks that weren't applied, because they
+	// haven't been trusted with proj trust
+	Untrusted bool
+}
+
+// Activate records a visit to a project, and works out what visiting it does
+func (fr *ProjectRepository) Activate(name string) (a *Activation, err error) {
+	a, err = fr.Activation(name)
+	if err != nil {
+		return
+	}
+
+	frecency, err := LoadFrecency()
+	if err == nil {
+		frecency.Visit(fr.name, name, time.Now())
+		err = frecency.Write()
+	}
+
+	if err != nil {
+		logrus.Debugf("Couldn't record visit: %v", err)
+	}
+	return a, nil
+}
+
+// Activation works out what visiting a project does, the project's own environment and hooks are only used if
+// they've been trusted
+func (fr *ProjectRepository) Activation(name string) (a *Activation, err error) {
+	folder := fr.Path(name)
+	if _, err := os.Stat(folder); os.IsNotExist(err) {
+		return nil, ErrNoSuchProject
+	} else if err != nil {
+		return nil, err
+	}
+
+	meta, err := fr.Metadata(name)
+	if err != nil {
+		return nil, errors.WithMessage(err, "while reading "+MetadataFile)
+	}
+
+	a = &Activation{Env: map[string]string{}}
+
+	trust, err := LoadTrust()
+	if err != nil {
+		return nil, errors.WithMessage(err, "while reading "+TrustPath)
+	}
+
+	trusted, err := trust.Trusted(folder, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	if trusted {
+		for k, v := range meta.Env {
+			a.Env[k] = v
+		}
+
+		for _, file := range meta.EnvFiles {
+			env, err := parseEnvFile(filepath.Join(folder, file))
+			if os.IsNotExist(err) {
+				logrus.Warnf("Env file %s doesn't exist", file)
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+
+			for k, v := range env {
+				a.Env[k] = v
+			}
+		}
+
+		if len(meta.Path) > 0 {
+			dirs := make([]string, 0, len(meta.Path)+1)
+			for _, dir := range meta.Path {
+				if !filepath.IsAbs(dir) {
+					dir = filepath.Join(folder, dir)
+				}
+				dirs = append(dirs, dir)
+			}
+
+			pathVar, exists := a.Env["PATH"]
+			if !exists {
+				pathVar = os.Getenv("PATH")
+			}
+			a.Env["PATH"] = strings.Join(append(dirs, pathVar), string(os.PathListSeparator))
+		}
+
+		a.OnEnter = meta.OnEnter
+		a.OnExit = meta.OnExit
+	} else {
+		a.Untrusted = true
+		logrus.Warnf("%s has an environment or hooks that aren't trusted, check them with proj info %s and run proj trust %s to use them", name, name, name)
+	}
+
+	a.Env["PROJ_CURRENT_PROJECT_BASE"] = folder
+	a.Env["PROJ_CURRENT_PROJECT_NAME"] = name
+	a.Env["HISTFILE"] = fr.HistFile(name)
+	a.Env["fish_history"] = fishHistoryName(fr.Id(name))
+	return
+}
+
+// runHooks runs shell commands in a folder, stopping at the first one that fails
+func runHooks(folder string, env []string, stdout io.Writer, hooks []string) (err error) {
+	for _, hook := range hooks {
+		logrus.Debugf("Running hook %q", hook)
+		cmd := exec.Command("sh", "-c", hook)
+		cmd.Dir = folder
+		cmd.Env = env
+		cmd.Stderr = os.Stderr
+		cmd.Stdout = stdout
+		cmd.Stdin = os.Stdin
+
+		err = cmd.Run()
+		if err != nil {
+			return errors.WithMessage(err, "hook "+hook)
+		}
+	}
+	return
+}
+
+// TrustProject trusts a project's current environment and hooks
+func (fr *ProjectRepository) TrustProject(name string) (err error) {
+	meta, err := fr.Metadata(name)
+	if err != nil {
+		return
+	}
+
+	trust, err := LoadTrust()
+	if err != nil {
+		return
+	}
+
+	err = trust.Allow(fr.Path(name), meta)
+	if err != nil {
+		return
+	}
+	return trust.Write()
+}
+
+// UntrustProject stops trusting a project's environment and hooks
+func (fr *ProjectRepository) UntrustProject(name string) (err error) {
+	trust, err := LoadTrust()
+	if err != nil {
+		return
+	}
+
+	trust.Revoke(fr.Path(name))
+	return trust.Write()
+}
+
+// IsTrusted is true if visiting a project applies its environment and runs its hooks
+func (fr *ProjectRepository) IsTrusted(name string) (bool, error) {
+	meta, err := fr.Metadata(name)
+	if err != nil {
+		return false, err
+	}
+
+	trust, err := LoadTrust()
+	if err != nil {
+		return false, err
+	}
+	return trust.Trusted(fr.Path(name), meta)
+}
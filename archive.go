@@ -0,0 +1,285 @@
+package proj
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var ArchivePath = path.Join(os.Getenv("HOME"), ".proj", "archive.json")
+
+var (
+	ErrProjectArchived     = errors.New("Project is archived, unarchive it first")
+	ErrNotArchived         = errors.New("Project isn't archived")
+	ErrArchiveNotVerified  = errors.New("The archived copy doesn't match the project, it was kept")
+	ErrArchiveIgnoredFiles = errors.New("The project has files ignored by its .gitignore, which wouldn't be uploaded")
+)
+
+// ArchivedProject records a project whose folder was removed once it was uploaded or backed up
+type ArchivedProject struct {
+	Repo     string    `json:"repo"`
+	Name     string    `json:"name"`
+	Archived time.Time `json:"archived"`
+
+	// Remote is the remote the project was uploaded to, if it was archived by uploading it
+	Remote string `json:"remote,omitempty"`
+
+	// BackupRepo and Snapshot are the snapshot the project was backed up to, if it was archived by backing it up
+	BackupRepo string `json:"backup-repo,omitempty"`
+	Snapshot   string `json:"snapshot,omitempty"`
+
+	// the project as it was when it was archived, so it can still be listed and queried
+	Metadata *Metadata `json:"metadata"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// Archive is every archived project, keyed by repository and project name
+type Archive map[string]*ArchivedProject
+
+// LoadArchive reads the archived projects, a missing archive file means no project is archived
+func LoadArchive() (archive Archive, err error) {
+	archive = make(Archive)
+	data, err := ioutil.ReadFile(ArchivePath)
+	if os.IsNotExist(err) {
+		return archive, nil
+	} else if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(data, &archive)
+	return
+}
+
+func (archive Archive) Write() error {
+	data, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(path.Dir(ArchivePath), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(ArchivePath, data, 0600)
+}
+
+// Get finds an archived project, it's nil if the project isn't archived
+func (archive Archive) Get(repo, name string) *ArchivedProject {
+	return archive[frecencyKey(repo, name)]
+}
+
+// Projects lists a repository's archived projects, sorted by name
+func (archive Archive) Projects(repo string) []*ArchivedProject {
+	projects := make([]*ArchivedProject, 0, len(archive))
+	for _, a := range archive {
+		if a.Repo == repo {
+			projects = append(projects, a)
+		}
+	}
+
+	sort.Slice(projects, func(i, j int) bool {
+		return projects[i].Name < projects[j].Name
+	})
+	return projects
+}
+
+// Archived finds a project's archive record, it's nil if the project isn't archived
+func (fr *ProjectRepository) Archived(name string) (*ArchivedProject, error) {
+	archive, err := LoadArchive()
+	if err != nil {
+		return nil, err
+	}
+	return archive.Get(fr.name, name), nil
+}
+
+// ignoredFiles lists the files in a project that its .gitignore keeps from being uploaded
+func ignoredFiles(folder string) (ignored []string, err error) {
+	ignore, err := loadIgnore(folder)
+	if err != nil || ignore == nil {
+		return
+	}
+
+	err = filepath.Walk(folder, func(file string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+
+		strippedFile, err := filepath.Rel(folder, file)
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() && ignore.MatchesPath(strippedFile) {
+			ignored = append(ignored, strippedFile)
+		}
+		return nil
+	})
+	return
+}
+
+// archive records a project as archived, then removes its folder
+func (fr *ProjectRepository) archive(name string, a *ArchivedProject) (err error) {
+	p := fr.Stat(name)
+	a.Repo, a.Name, a.Archived = fr.name, name, time.Now()
+	if a.Metadata, err = p.Metadata(); err != nil {
+		return
+	}
+	if a.Size, err = p.Size(); err != nil {
+		return
+	}
+	if a.Modified, err = p.ModTime(); err != nil {
+		return
+	}
+
+	archive, err := LoadArchive()
+	if err != nil {
+		return
+	}
+
+	archive[frecencyKey(fr.name, name)] = a
+	err = archive.Write()
+	if err != nil {
+		return
+	}
+
+	logrus.Debugf("Removing %s", fr.Path(name))
+	return os.RemoveAll(fr.Path(name))
+}
+
+// ArchiveToRemote uploads a project to a StorageService, checks every file was uploaded, then removes the
+// project's folder. Files the project's .gitignore ignores aren't uploaded, so projects with ignored files are
+// only archived if discardIgnored is true.
+func (fr *ProjectRepository) ArchiveToRemote(name, remote string, s StorageService, discardIgnored bool) (err error) {
+	folder := fr.Path(name)
+	if _, err := os.Stat(folder); os.IsNotExist(err) {
+		return ErrNoSuchProject
+	} else if err != nil {
+		return err
+	}
+
+	ignored, err := ignoredFiles(folder)
+	if err != nil {
+		return
+	}
+
+	if len(ignored) > 0 && !discardIgnored {
+		return errors.WithMessage(ErrArchiveIgnoredFiles, strings.Join(ignored, ", "))
+	}
+
+	err = fr.Upload(name, s)
+	if err != nil {
+		return
+	}
+
+	status, err := fr.SyncStatus(name, s)
+	if err != nil {
+		return
+	}
+
+	if status != SyncStatusSynced {
+		return ErrArchiveNotVerified
+	}
+	return fr.archive(name, &ArchivedProject{Remote: remote})
+}
+
+// ArchiveToBackup backs a project up to a repository, verifies the backup, then removes the project's folder
+func (fr *ProjectRepository) ArchiveToBackup(name string, bs BackupService, repo string) (err error) {
+	err = fr.Backup(bs, name, repo)
+	if err != nil {
+		return
+	}
+
+	result, err := fr.VerifyBackup(bs, name, repo)
+	if err != nil {
+		return
+	}
+
+	// files changed since the snapshot was taken would be lost too
+	if !result.OK() || len(result.Changed) > 0 {
+		return ErrArchiveNotVerified
+	}
+	return fr.archive(name, &ArchivedProject{BackupRepo: repo, Snapshot: result.Snapshot.ID})
+}
+
+// unarchived forgets a project's archive record, once it's been restored
+func (fr *ProjectRepository) unarchived(name string) error {
+	archive, err := LoadArchive()
+	if err != nil {
+		return err
+	}
+
+	key := frecencyKey(fr.name, name)
+	if _, exists := archive[key]; !exists {
+		return nil
+	}
+
+	delete(archive, key)
+	return archive.Write()
+}
+
+// UnarchiveFromRemote downloads a project archived with ArchiveToRemote
+func (fr *ProjectRepository) UnarchiveFromRemote(name string, s StorageService) (err error) {
+	if _, err := os.Stat(fr.Path(name)); err == nil {
+		return ErrProjectExists
+	}
+
+	err = fr.Pull(name, s)
+	if err != nil {
+		return
+	}
+	return fr.unarchived(name)
+}
+
+// UnarchiveFromBackup restores a project archived with ArchiveToBackup
+func (fr *ProjectRepository) UnarchiveFromBackup(name string, bs BackupService) (err error) {
+	a, err := fr.Archived(name)
+	if err != nil {
+		return
+	} else if a == nil {
+		return ErrNotArchived
+	}
+
+	if _, err := os.Stat(fr.Path(name)); err == nil {
+		return ErrProjectExists
+	}
+
+	err = fr.RestoreSnapshot(bs, name, a.BackupRepo, a.Snapshot, fr.Path(name))
+	if err != nil {
+		return
+	}
+	return fr.unarchived(name)
+}
+
+// QueryArchived lists the archived projects matching a query
+func (fr *ProjectRepository) QueryArchived(q Query) (matches []*ProjectStat, err error) {
+	archive, err := LoadArchive()
+	if err != nil {
+		return
+	}
+
+	for _, a := range archive.Projects(fr.name) {
+		p := &ProjectStat{Repo: fr, Name: a.Name, Archive: a, meta: a.Metadata, modTime: a.Modified, size: a.Size, walked: true}
+		if p.meta == nil {
+			p.meta = &Metadata{}
+		}
+
+		ok, err := q.Matches(p)
+		if err != nil {
+			return nil, errors.WithMessage(err, p.Name)
+		}
+
+		if ok {
+			matches = append(matches, p)
+		}
+	}
+	return
+}
@@ -1,46 +1,348 @@
 package proj
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 	"path"
-
-	"github.com/otiai10/copy"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var ErrNoResticRepos = errors.New("No restic repos added")
 var ErrResticNotFound = errors.New("Restic executable not found")
+var ErrNoSuchSnapshot = errors.New("No such snapshot")
+var ErrEmptyRetentionPolicy = errors.New("Retention policy doesn't keep any snapshots")
+var ErrAmbiguousID = errors.New("Snapshot ID matches more than one snapshot")
+var ErrTargetNotEmpty = errors.New("Restore target isn't empty")
+
+// SnapshotLatest can be passed to FindSnapshot to find the most recent snapshot
+const SnapshotLatest = "latest"
 
 type BackupService interface {
-	Backup(folder string, repositories ...string) error
-	Restore(folder, repository string) error
+	// Backup folder to every repository, labeling the backup with tags
+	Backup(folder string, tags []string, repositories ...string) error
+
+	// Restore the latest snapshot listed by Snapshots into folder
+	Restore(folder, repository string, tags []string) error
+
+	// Snapshots lists the snapshots in a repository labeled with any of tags, along with the unlabeled snapshots
+	// of folder, oldest first. Every snapshot is listed if folder is empty.
+	Snapshots(folder, repository string, tags []string) ([]Snapshot, error)
+
+	// RestoreSnapshot restores a snapshot into target, from the folder it was taken of. The contents of target
+	// are replaced by the snapshot rather than merged with it.
+	RestoreSnapshot(repository string, snapshot Snapshot, target string) error
 }
 
+// Pruner is implemented by a BackupService that can remove old snapshots
+type Pruner interface {
+	// Prune removes the snapshots with every one of tags that aren't kept by policy
+	Prune(repository string, tags []string, policy RetentionPolicy) error
+}
 
-type Restic struct{}
+// RetentionPolicy decides which snapshots are kept when pruning, a zero field keeps nothing in that category
+type RetentionPolicy struct {
+	KeepLast    int `json:"keep-last,omitempty"`
+	KeepDaily   int `json:"keep-daily,omitempty"`
+	KeepWeekly  int `json:"keep-weekly,omitempty"`
+	KeepMonthly int `json:"keep-monthly,omitempty"`
+}
 
-// Backup a given resource using restic
-func (r Restic) Backup(folder string, repos ...string) (err error) {
-	if len(repos) == 0 {
-		return ErrNoResticRepos
+// IsZero is true if the policy doesn't keep anything, pruning with an empty policy is refused
+func (p RetentionPolicy) IsZero() bool {
+	return p == RetentionPolicy{}
+}
+
+func (p RetentionPolicy) String() string {
+	return fmt.Sprintf("keep-last=%d keep-daily=%d keep-weekly=%d keep-monthly=%d",
+		p.KeepLast, p.KeepDaily, p.KeepWeekly, p.KeepMonthly)
+}
+
+// Keep picks the snapshots the policy keeps, by ID, the way restic's forget does: the latest KeepLast snapshots,
+// and the latest snapshot of each of the last KeepDaily days, KeepWeekly weeks and KeepMonthly months with one
+func (p RetentionPolicy) Keep(snapshots []Snapshot) map[string]bool {
+	newest := make([]Snapshot, len(snapshots))
+	copy(newest, snapshots)
+	sort.SliceStable(newest, func(i, j int) bool {
+		return newest[i].Time.After(newest[j].Time)
+	})
+
+	buckets := []struct {
+		n   int
+		key func(s Snapshot) string
+	}{
+		{p.KeepLast, func(s Snapshot) string { return s.ID }},
+		{p.KeepDaily, func(s Snapshot) string { return s.Time.Local().Format("2006-01-02") }},
+		{p.KeepWeekly, func(s Snapshot) string {
+			year, week := s.Time.Local().ISOWeek()
+			return fmt.Sprintf("%d-%d", year, week)
+		}},
+		{p.KeepMonthly, func(s Snapshot) string { return s.Time.Local().Format("2006-01") }},
 	}
 
-	restic, err := exec.LookPath("restic")
-	if err != nil {
-		if os.IsNotExist(err) {
-			return ErrResticNotFound
+	keep := make(map[string]bool)
+	for _, bucket := range buckets {
+		kept, last := 0, ""
+		for _, snapshot := range newest {
+			if kept >= bucket.n {
+				break
+			}
+
+			if key := bucket.key(snapshot); key != last {
+				keep[snapshot.ID] = true
+				kept++
+				last = key
+			}
 		}
+	}
+	return keep
+}
+
+// pruneSelect picks the snapshots, with every one of tags, that policy doesn't keep, for services that prune
+// snapshot by snapshot
+func pruneSelect(snapshots []Snapshot, tags []string, policy RetentionPolicy) (forget []Snapshot) {
+	tagged := make([]Snapshot, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		if snapshot.hasTags(tags) {
+			tagged = append(tagged, snapshot)
+		}
+	}
+
+	keep := policy.Keep(tagged)
+	for _, snapshot := range tagged {
+		if !keep[snapshot.ID] {
+			forget = append(forget, snapshot)
+		}
+	}
+	return
+}
+
+// Snapshot is a single backup of a folder
+type Snapshot struct {
+	ID       string    `json:"id"`
+	ShortID  string    `json:"short_id"`
+	Time     time.Time `json:"time"`
+	Paths    []string  `json:"paths"`
+	Tags     []string  `json:"tags"`
+	Hostname string    `json:"hostname"`
+}
+
+// hasTags is true if the snapshot is labeled with every one of tags
+func (s Snapshot) hasTags(tags []string) bool {
+	for _, t := range tags {
+		found := false
+		for _, tag := range s.Tags {
+			found = found || tag == t
+		}
+
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// matches is true if the snapshot is labeled with any of tags, snapshots taken before backups were labeled
+// with the project's Id are matched by folder instead. Every snapshot matches an empty folder.
+func (s Snapshot) matches(folder string, tags []string) bool {
+	if folder == "" {
+		return true
+	}
+
+	labeled := false
+	for _, tag := range s.Tags {
+		for _, t := range tags {
+			if tag == t {
+				return true
+			}
+		}
+		labeled = labeled || strings.HasPrefix(tag, "proj-id:")
+	}
+
+	if labeled {
+		return false
+	}
+	for _, p := range s.Paths {
+		if p == folder {
+			return true
+		}
+	}
+	return false
+}
+
+// filterSnapshots keeps the snapshots matching folder and tags, oldest first
+func filterSnapshots(all []Snapshot, folder string, tags []string) (snapshots []Snapshot) {
+	snapshots = make([]Snapshot, 0, len(all))
+	for _, snapshot := range all {
+		if snapshot.matches(folder, tags) {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.Before(snapshots[j].Time)
+	})
+	return
+}
+
+// FindSnapshot finds a snapshot by ID or ID prefix, or the latest snapshot if id is SnapshotLatest
+func FindSnapshot(snapshots []Snapshot, id string) (found Snapshot, err error) {
+	if id == SnapshotLatest {
+		if len(snapshots) == 0 {
+			return found, ErrNoSuchSnapshot
+		}
+		return snapshots[len(snapshots)-1], nil
+	}
+
+	matches := 0
+	for _, snapshot := range snapshots {
+		if snapshot.ID == id {
+			return snapshot, nil
+		}
+
+		if strings.HasPrefix(snapshot.ID, id) {
+			found = snapshot
+			matches++
+		}
+	}
+
+	switch matches {
+	case 0:
+		return found, ErrNoSuchSnapshot
+	case 1:
+		return found, nil
+	default:
+		return found, ErrAmbiguousID
+	}
+}
+
+// restoreLatest restores the latest of a folder's snapshots into the folder
+func restoreLatest(bs BackupService, folder, repository string, tags []string) error {
+	snapshots, err := bs.Snapshots(folder, repository, tags)
+	if err != nil {
 		return err
 	}
 
-	for _, r := range repos {
-		cmd := exec.Command(restic, "--repo", r, "backup", folder)
-		cmd.Stderr = os.Stderr
-		cmd.Stdout = os.Stdout
+	latest, err := FindSnapshot(snapshots, SnapshotLatest)
+	if err != nil {
+		return err
+	}
+	return bs.RestoreSnapshot(repository, latest, folder)
+}
+
+// SnapshotAt finds the last snapshot taken at or before t
+func SnapshotAt(snapshots []Snapshot, t time.Time) (Snapshot, error) {
+	found := -1
+	for i, snapshot := range snapshots {
+		if !snapshot.Time.After(t) && (found == -1 || snapshot.Time.After(snapshots[found].Time)) {
+			found = i
+		}
+	}
+
+	if found == -1 {
+		return Snapshot{}, ErrNoSuchSnapshot
+	}
+	return snapshots[found], nil
+}
+
+
+// ResticOptions are the settings for a single restic repository
+type ResticOptions struct {
+	// PasswordFile is a file containing the repository's password
+	PasswordFile string `json:"password-file,omitempty"`
+
+	// PasswordCommand is a shell command that prints the repository's password
+	PasswordCommand string `json:"password-command,omitempty"`
+
+	// Env is extra environment passed to restic (e.g. AWS_ACCESS_KEY_ID, B2_ACCOUNT_KEY)
+	Env map[string]string `json:"env,omitempty"`
+}
+
+// HasPassword is true if restic can get the password without asking the user
+func (opts *ResticOptions) HasPassword() bool {
+	return opts.PasswordFile != "" || opts.PasswordCommand != "" ||
+		opts.Env["RESTIC_PASSWORD"] != "" || opts.Env["RESTIC_PASSWORD_FILE"] != "" || opts.Env["RESTIC_PASSWORD_COMMAND"] != ""
+}
+
+func (opts *ResticOptions) environ() map[string]string {
+	env := make(map[string]string, len(opts.Env)+2)
+	for k, v := range opts.Env {
+		env[k] = v
+	}
+
+	if opts.PasswordFile != "" {
+		env["RESTIC_PASSWORD_FILE"] = opts.PasswordFile
+	}
+	if opts.PasswordCommand != "" {
+		env["RESTIC_PASSWORD_COMMAND"] = opts.PasswordCommand
+	}
+	return env
+}
+
+// Restic is a BackupService that uses the restic executable
+type Restic struct {
+	// Options are the settings for each repository, keyed by repository
+	Options map[string]*ResticOptions
+}
+
+// lookupExecutable finds an executable in the PATH, returning notFound if it isn't installed
+func lookupExecutable(name string, notFound error) (string, error) {
+	exe, err := exec.LookPath(name)
+	if err != nil {
+		if execErr, ok := err.(*exec.Error); ok && execErr.Err == exec.ErrNotFound {
+			return "", notFound
+		}
+		return "", err
+	}
+	return exe, nil
+}
+
+// command prepares a restic command for a repository, restic is only connected to stdin if it may need to ask for a password
+func (r Restic) command(repository string, args ...string) (*exec.Cmd, error) {
+	restic, err := lookupExecutable("restic", ErrResticNotFound)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(restic, append([]string{"--repo", repository}, args...)...)
+	cmd.Stderr = os.Stderr
+	cmd.Stdout = os.Stdout
+
+	if opts := r.Options[repository]; opts != nil {
+		cmd.Env = modEnviron(opts.environ())
+		if !opts.HasPassword() {
+			cmd.Stdin = os.Stdin
+		}
+	} else {
 		cmd.Stdin = os.Stdin
+	}
+	return cmd, nil
+}
+
+// Backup a given resource using restic
+func (r Restic) Backup(folder string, tags []string, repos ...string) (err error) {
+	if len(repos) == 0 {
+		return ErrNoResticRepos
+	}
+
+	args := []string{"backup", folder}
+	for _, tag := range tags {
+		args = append(args, "--tag", tag)
+	}
+
+	for _, repo := range repos {
+		cmd, err := r.command(repo, args...)
+		if err != nil {
+			return err
+		}
 
 		err = cmd.Run()
 		if err != nil {
@@ -51,36 +353,90 @@ func (r Restic) Backup(folder string, repos ...string) (err error) {
 	return
 }
 
-func (r Restic) Restore(folder string, repository string) (err error) {
-	restic, err := exec.LookPath("restic")
-	if err != nil {
-		if os.IsNotExist(err) {
-			return ErrResticNotFound
+// Prune forgets the snapshots tagged with every one of tags that policy doesn't keep, and prunes the repository
+func (r Restic) Prune(repository string, tags []string, policy RetentionPolicy) (err error) {
+	if policy.IsZero() {
+		return ErrEmptyRetentionPolicy
+	}
+
+	args := []string{"forget", "--prune"}
+	if len(tags) > 0 {
+		args = append(args, "--tag", strings.Join(tags, ","))
+	}
+
+	keep := []struct {
+		flag string
+		n    int
+	}{
+		{"--keep-last", policy.KeepLast},
+		{"--keep-daily", policy.KeepDaily},
+		{"--keep-weekly", policy.KeepWeekly},
+		{"--keep-monthly", policy.KeepMonthly},
+	}
+	for _, k := range keep {
+		if k.n > 0 {
+			args = append(args, k.flag, strconv.Itoa(k.n))
 		}
+	}
+
+	cmd, err := r.command(repository, args...)
+	if err != nil {
 		return err
 	}
+	return cmd.Run()
+}
+
+// Snapshots lists the restic snapshots labeled with any of tags, or the unlabeled ones that include folder. Every
+// snapshot is listed, so ones taken before a project was renamed or moved are found by their tags.
+func (r Restic) Snapshots(folder, repository string, tags []string) (snapshots []Snapshot, err error) {
+	cmd, err := r.command(repository, "snapshots", "--json")
+	if err != nil {
+		return
+	}
 
-	hashed := sha256.Sum256([]byte(folder))
+	out := &bytes.Buffer{}
+	cmd.Stdout = out
+	err = cmd.Run()
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(out.Bytes(), &snapshots)
+	if err != nil {
+		return
+	}
+	return filterSnapshots(snapshots, folder, tags), nil
+}
+
+// Restore the latest backup of a folder using restic
+func (r Restic) Restore(folder string, repository string, tags []string) (err error) {
+	return restoreLatest(r, folder, repository, tags)
+}
+
+// RestoreSnapshot restores a restic snapshot to target
+func (r Restic) RestoreSnapshot(repository string, snapshot Snapshot, target string) (err error) {
+	if len(snapshot.Paths) == 0 {
+		return ErrNoSuchSnapshot
+	}
+	folder := snapshot.Paths[0]
+
+	hashed := sha256.Sum256([]byte(target))
 	tmpdir := path.Join(os.TempDir(), "proj-restic-mount_"+hex.EncodeToString(hashed[:]))
 	os.RemoveAll(tmpdir)
 
-	cmd := exec.Command(restic,
-		"--repo", repository,
-		"restore", "latest",
-		"--target", tmpdir,
-		"--path", folder)
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
-	cmd.Stdin = os.Stdin
-	err = cmd.Run()
+	cmd, err := r.command(repository,
+		"restore", snapshot.ID,
+		"--target", tmpdir)
 	if err != nil {
 		return err
 	}
 
-	err = os.Rename(path.Join(tmpdir, folder), folder)
+	err = cmd.Run()
 	if err != nil {
-		err = copy.Copy(path.Join(tmpdir, folder), folder)
+		return err
 	}
+
+	err = replaceFolder(path.Join(tmpdir, folder), target)
 	os.RemoveAll(tmpdir)
 
 	return
@@ -0,0 +1,352 @@
+package proj
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// fakeResticScript records the arguments of every call, one line per call, lists the snapshots in snapshots.json
+// and restores the files in snapshot/ to the path in snapshot-path
+const fakeResticScript = `#!/bin/sh
+dir=$(dirname "$0")
+(IFS='	'; echo "$*") >> "$dir/argv"
+case "$3" in
+snapshots) cat "$dir/snapshots.json" ;;
+restore) mkdir -p "$6$(cat "$dir/snapshot-path")" && cp -R "$dir/snapshot/." "$6$(cat "$dir/snapshot-path")" ;;
+esac
+`
+
+// fakeRestic puts a fake restic executable first in the PATH, it's removed by the returned function
+func fakeRestic(t *testing.T) (dir string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "proj-fake-restic")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(path.Join(dir, "restic"), []byte(fakeResticScript), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldPath := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+oldPath)
+	return dir, func() {
+		os.Setenv("PATH", oldPath)
+		os.RemoveAll(dir)
+	}
+}
+
+// resticCalls reads the arguments of every call to the fake restic
+func resticCalls(t *testing.T, dir string) (calls [][]string) {
+	data, err := ioutil.ReadFile(path.Join(dir, "argv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		calls = append(calls, strings.Split(line, "\t"))
+	}
+	return
+}
+
+// testRepository makes a repository with a project named foo, with the persistent Id foo-id, proj's own files
+// are kept next to it until cleanup
+func testRepository(t *testing.T) (fr *ProjectRepository, cleanup func()) {
+	dir, err := ioutil.TempDir("", "proj-test-repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldIdFolders, oldTrash := IdFoldersPath, TrashPath
+	IdFoldersPath = path.Join(dir, ".proj", "ids.json")
+	TrashPath = path.Join(dir, ".proj", "trash")
+	cleanup = func() {
+		IdFoldersPath, TrashPath = oldIdFolders, oldTrash
+		os.RemoveAll(dir)
+	}
+
+	fr = NewLocal("main", path.Join(dir, "projects"))
+	err = os.MkdirAll(fr.Path("foo"), projectFolderPerm)
+	if err == nil {
+		err = fr.WriteMetadata("foo", &Metadata{Id: "foo-id"})
+	}
+	if err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	return fr, cleanup
+}
+
+func TestResticBackupArgs(t *testing.T) {
+	dir, cleanup := fakeRestic(t)
+	defer cleanup()
+	fr, cleanupRepo := testRepository(t)
+	defer cleanupRepo()
+
+	err := fr.Backup(Restic{}, "foo", "/backups/one", "/backups/two")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]string{
+		{"--repo", "/backups/one", "backup", fr.Path("foo"), "--tag", "proj-id:foo-id", "--tag", "proj-name:foo"},
+		{"--repo", "/backups/two", "backup", fr.Path("foo"), "--tag", "proj-id:foo-id", "--tag", "proj-name:foo"},
+	}
+	if calls := resticCalls(t, dir); !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected calls %q, got %q", expected, calls)
+	}
+}
+
+func TestResticRestoreArgs(t *testing.T) {
+	dir, cleanup := fakeRestic(t)
+	defer cleanup()
+	fr, cleanupRepo := testRepository(t)
+	defer cleanupRepo()
+
+	// the latest snapshot of foo was taken before it was renamed from old-foo
+	snapshots := `[
+		{"id": "aaaa1111", "short_id": "aaaa", "time": "2020-01-01T10:00:00Z", "paths": ["` + fr.Path("old-foo") + `"], "tags": ["proj-id:foo-id"]},
+		{"id": "bbbb2222", "short_id": "bbbb", "time": "2020-02-01T10:00:00Z", "paths": ["` + fr.Path("old-foo") + `"], "tags": ["proj-id:foo-id"]},
+		{"id": "cccc3333", "short_id": "cccc", "time": "2020-03-01T10:00:00Z", "paths": ["` + fr.Path("bar") + `"], "tags": ["proj-id:bar-id"]}
+	]`
+	for file, content := range map[string]string{
+		"snapshots.json":    snapshots,
+		"snapshot-path":     fr.Path("old-foo"),
+		"snapshot/restored": "restored\n",
+	} {
+		os.MkdirAll(path.Dir(path.Join(dir, file)), 0755)
+		if err := ioutil.WriteFile(path.Join(dir, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// files that aren't in the snapshot are moved to the trash with the rest of the project
+	err := ioutil.WriteFile(path.Join(fr.Path("foo"), "stale"), nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fr.Restore(Restic{}, "foo", "/backups/one")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := resticCalls(t, dir)
+	if len(calls) != 2 {
+		t.Fatalf("expected a snapshots and a restore call, got %q", calls)
+	}
+
+	if expected := []string{"--repo", "/backups/one", "snapshots", "--json"}; !reflect.DeepEqual(calls[0], expected) {
+		t.Errorf("expected call %q, got %q", expected, calls[0])
+	}
+
+	if len(calls[1]) != 6 || calls[1][2] != "restore" || calls[1][3] != "bbbb2222" || calls[1][4] != "--target" {
+		t.Errorf("expected a restore of snapshot bbbb2222, got %q", calls[1])
+	}
+
+	if data, err := ioutil.ReadFile(path.Join(fr.Path("foo"), "restored")); err != nil || string(data) != "restored\n" {
+		t.Errorf("expected the snapshot's files to be restored, got %q (%v)", data, err)
+	}
+
+	if _, err := os.Stat(path.Join(fr.Path("foo"), "stale")); !os.IsNotExist(err) {
+		t.Errorf("expected files that aren't in the snapshot to be removed, got %v", err)
+	}
+
+	trashed, err := FindTrashed("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(path.Join(trashed.Path(), "stale")); err != nil || trashed.Reason != TrashReasonOverwritten {
+		t.Errorf("expected the old project to be in the trash, got %q (%v)", trashed.Reason, err)
+	}
+}
+
+// recordingBackupService records the calls made to it, restores fail with err
+type recordingBackupService struct {
+	restored []string
+	tags     []string
+	err      error
+}
+
+func (rb *recordingBackupService) Backup(folder string, tags []string, repositories ...string) error {
+	return nil
+}
+
+func (rb *recordingBackupService) Restore(folder, repository string, tags []string) error {
+	rb.restored = append(rb.restored, folder+" from "+repository)
+	rb.tags = tags
+	return rb.err
+}
+
+func (rb *recordingBackupService) Snapshots(folder, repository string, tags []string) ([]Snapshot, error) {
+	return nil, nil
+}
+
+func (rb *recordingBackupService) RestoreSnapshot(repository string, snapshot Snapshot, target string) error {
+	return nil
+}
+
+func TestProjectRestoreCallsRestore(t *testing.T) {
+	fr, cleanupRepo := testRepository(t)
+	defer cleanupRepo()
+
+	bs := &recordingBackupService{}
+	err := fr.Restore(bs, "foo", "/backups/one")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{fr.Path("foo") + " from /backups/one"}; !reflect.DeepEqual(bs.restored, expected) {
+		t.Errorf("expected restores %q, got %q", expected, bs.restored)
+	}
+
+	if expected := []string{"proj-id:foo-id"}; !reflect.DeepEqual(bs.tags, expected) {
+		t.Errorf("expected tags %q, got %q", expected, bs.tags)
+	}
+}
+
+func TestFailedRestoreKeepsProject(t *testing.T) {
+	fr, cleanupRepo := testRepository(t)
+	defer cleanupRepo()
+
+	bs := &recordingBackupService{err: errors.New("restore failed")}
+	err := fr.Restore(bs, "foo", "/backups/one")
+	if err != bs.err {
+		t.Fatalf("expected %v, got %v", bs.err, err)
+	}
+
+	if id := fr.Id("foo"); id != "foo-id" {
+		t.Errorf("expected the project to be put back, got Id %q", id)
+	}
+
+	if trashed, err := ListTrash(); err != nil || len(trashed) != 0 {
+		t.Errorf("expected nothing in the trash, got %v (%v)", trashed, err)
+	}
+}
+
+func TestBackedUp(t *testing.T) {
+	dir, cleanup := fakeRestic(t)
+	defer cleanup()
+	fr, cleanupRepo := testRepository(t)
+	defer cleanupRepo()
+
+	// foo was renamed to renamed, gone was removed locally, and elsewhere is in another repository
+	snapshots := `[
+		{"id": "1", "time": "2020-01-01T10:00:00Z", "paths": ["` + fr.Path("foo") + `"], "tags": ["proj-id:foo-id", "proj-name:foo"]},
+		{"id": "2", "time": "2020-02-01T10:00:00Z", "paths": ["` + fr.Path("renamed") + `"], "tags": ["proj-id:foo-id", "proj-name:renamed"]},
+		{"id": "3", "time": "2020-01-01T10:00:00Z", "paths": ["` + fr.Path("gone") + `"], "tags": ["proj-id:gone-id", "proj-name:gone"]},
+		{"id": "4", "time": "2020-01-01T10:00:00Z", "paths": ["` + fr.Path("untagged") + `"]},
+		{"id": "5", "time": "2020-01-01T10:00:00Z", "paths": ["/elsewhere/project"], "tags": ["proj-id:other-id"]}
+	]`
+	err := ioutil.WriteFile(path.Join(dir, "snapshots.json"), []byte(snapshots), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := fr.BackedUp(Restic{}, "/backups/one")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"gone", "renamed", "untagged"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected projects %q, got %q", expected, names)
+	}
+}
+
+func TestNativeRestoreRejectsUnsafeManifests(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proj-test-native")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	outside := path.Join(dir, "outside")
+	err = os.Mkdir(outside, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, files := range map[string][]nativeFile{
+		"parent":   {{Path: "../evil", Mode: 0644}},
+		"absolute": {{Path: path.Join(outside, "evil"), Mode: 0644}},
+		"symlink": {
+			{Path: "link", Mode: os.ModeSymlink | 0777, Link: outside},
+			{Path: "link/evil", Mode: 0644},
+		},
+	} {
+		repository := path.Join(dir, "repo-"+name)
+		manifest, err := json.Marshal(nativeManifest{Snapshot: Snapshot{ID: name}, Files: files})
+		if err == nil {
+			err = os.MkdirAll(path.Join(repository, "snapshots"), 0755)
+		}
+		if err == nil {
+			err = ioutil.WriteFile(path.Join(repository, "snapshots", name+".json"), manifest, 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = Native{}.RestoreSnapshot(repository, Snapshot{ID: name}, path.Join(dir, "target-"+name))
+		if errors.Cause(err) != ErrUnsafeManifest {
+			t.Errorf("%s: expected %v, got %v", name, ErrUnsafeManifest, err)
+		}
+
+		if _, err := os.Stat(path.Join(outside, "evil")); !os.IsNotExist(err) {
+			t.Errorf("%s: expected nothing to be written outside of the target, got %v", name, err)
+		}
+	}
+}
+
+func TestRetentionPolicyKeep(t *testing.T) {
+	at := func(s string) time.Time {
+		parsed, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	// oldest first, like the snapshots backup services list
+	snapshots := []Snapshot{
+		{ID: "jan", Time: at("2020-01-15 10:00")},
+		{ID: "feb-early", Time: at("2020-02-03 10:00")},
+		{ID: "feb-late", Time: at("2020-02-20 10:00")},
+		{ID: "mon-morning", Time: at("2020-03-02 09:00")},
+		{ID: "mon-evening", Time: at("2020-03-02 18:00")},
+		{ID: "tue", Time: at("2020-03-03 10:00")},
+	}
+
+	for _, test := range []struct {
+		policy RetentionPolicy
+		kept   []string
+	}{
+		{RetentionPolicy{KeepLast: 2}, []string{"mon-evening", "tue"}},
+		{RetentionPolicy{KeepDaily: 2}, []string{"mon-evening", "tue"}},
+		{RetentionPolicy{KeepDaily: 3}, []string{"feb-late", "mon-evening", "tue"}},
+		{RetentionPolicy{KeepWeekly: 2}, []string{"feb-late", "tue"}},
+		{RetentionPolicy{KeepMonthly: 3}, []string{"feb-late", "jan", "tue"}},
+		{RetentionPolicy{KeepLast: 1, KeepMonthly: 2}, []string{"feb-late", "tue"}},
+		{RetentionPolicy{KeepMonthly: 10}, []string{"feb-late", "jan", "tue"}},
+	} {
+		keep := test.policy.Keep(snapshots)
+		kept := make([]string, 0, len(keep))
+		for id := range keep {
+			kept = append(kept, id)
+		}
+		sort.Strings(kept)
+
+		if !reflect.DeepEqual(kept, test.kept) {
+			t.Errorf("%s: expected %q to be kept, got %q", test.policy, test.kept, kept)
+		}
+	}
+}
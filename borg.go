@@ -0,0 +1,196 @@
+package proj
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+)
+
+var ErrNoBorgRepos = errors.New("No borg repos added")
+var ErrBorgNotFound = errors.New("Borg executable not found")
+
+// borgTimeLayout is the format of archive names' timestamps
+const borgTimeLayout = "2006-01-02T15:04:05"
+
+// borgPathTag marks the folder an archive was created from, in the space separated comments of archives made
+// before comments were JSON
+const borgPathTag = "proj-path:"
+
+// borgComment is kept, as JSON, in the comment of every archive proj creates
+type borgComment struct {
+	Path string   `json:"path"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+// parseBorgComment reads an archive's comment, ok is false if the archive wasn't created by proj
+func parseBorgComment(comment string) (parsed borgComment, ok bool) {
+	if json.Unmarshal([]byte(comment), &parsed) == nil {
+		return parsed, parsed.Path != ""
+	}
+
+	fields := strings.Fields(comment)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], borgPathTag) {
+		return parsed, false
+	}
+	return borgComment{Path: strings.TrimPrefix(fields[0], borgPathTag), Tags: fields[1:]}, true
+}
+
+// Borg is a BackupService that uses BorgBackup. Archives are named {project}-{timestamp}, and the archive's
+// comment holds the backed up folder and its tags.
+type Borg struct{}
+
+func (b Borg) command(args ...string) (*exec.Cmd, error) {
+	borg, err := lookupExecutable("borg", ErrBorgNotFound)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(borg, args...)
+	cmd.Stderr = os.Stderr
+	cmd.Stdout = os.Stdout
+	cmd.Stdin = os.Stdin
+	return cmd, nil
+}
+
+// Backup a folder to every repository using borg
+func (b Borg) Backup(folder string, tags []string, repos ...string) (err error) {
+	if len(repos) == 0 {
+		return ErrNoBorgRepos
+	}
+
+	archive := path.Base(folder) + "-" + time.Now().Format(borgTimeLayout)
+	comment, err := json.Marshal(borgComment{Path: folder, Tags: tags})
+	if err != nil {
+		return
+	}
+
+	for _, repo := range repos {
+		cmd, err := b.command("create", "--comment", string(comment), repo+"::"+archive, folder)
+		if err != nil {
+			return err
+		}
+
+		err = cmd.Run()
+		if err != nil {
+			return err
+		}
+	}
+	return
+}
+
+// Snapshots lists the borg archives labeled with any of tags, or the unlabeled archives of folder
+func (b Borg) Snapshots(folder, repository string, tags []string) (snapshots []Snapshot, err error) {
+	cmd, err := b.command("list", "--json", "--format", "{comment}{hostname}", repository)
+	if err != nil {
+		return
+	}
+
+	out := &bytes.Buffer{}
+	cmd.Stdout = out
+	err = cmd.Run()
+	if err != nil {
+		return
+	}
+
+	var list struct {
+		Archives []struct {
+			Name     string `json:"name"`
+			ID       string `json:"id"`
+			Start    string `json:"start"`
+			Comment  string `json:"comment"`
+			Hostname string `json:"hostname"`
+		} `json:"archives"`
+	}
+
+	err = json.Unmarshal(out.Bytes(), &list)
+	if err != nil {
+		return
+	}
+
+	snapshots = make([]Snapshot, 0, len(list.Archives))
+	for _, archive := range list.Archives {
+		comment, ok := parseBorgComment(archive.Comment)
+		if !ok {
+			continue
+		}
+
+		start, err := time.ParseInLocation("2006-01-02T15:04:05.999999", archive.Start, time.Local)
+		if err != nil {
+			return nil, err
+		}
+
+		snapshots = append(snapshots, Snapshot{
+			ID:       archive.Name,
+			ShortID:  archive.Name,
+			Time:     start,
+			Paths:    []string{comment.Path},
+			Tags:     comment.Tags,
+			Hostname: archive.Hostname,
+		})
+	}
+	return filterSnapshots(snapshots, folder, tags), nil
+}
+
+// Restore the latest borg archive of a folder
+func (b Borg) Restore(folder, repository string, tags []string) error {
+	return restoreLatest(b, folder, repository, tags)
+}
+
+// RestoreSnapshot extracts a borg archive into a temporary folder, then moves it to target
+func (b Borg) RestoreSnapshot(repository string, snapshot Snapshot, target string) (err error) {
+	if len(snapshot.Paths) == 0 {
+		return ErrNoSuchSnapshot
+	}
+
+	tmpdir, err := ioutil.TempDir("", "proj-borg-extract")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(tmpdir)
+
+	cmd, err := b.command("extract", repository+"::"+snapshot.ID)
+	if err != nil {
+		return
+	}
+
+	cmd.Dir = tmpdir
+	err = cmd.Run()
+	if err != nil {
+		return
+	}
+
+	// borg stores paths without the leading slash
+	extracted := path.Join(tmpdir, strings.TrimPrefix(snapshot.Paths[0], "/"))
+	return replaceFolder(extracted, target)
+}
+
+// Prune deletes the archives labeled with every one of tags that policy doesn't keep
+func (b Borg) Prune(repository string, tags []string, policy RetentionPolicy) (err error) {
+	if policy.IsZero() {
+		return ErrEmptyRetentionPolicy
+	}
+
+	snapshots, err := b.Snapshots("", repository, nil)
+	if err != nil {
+		return
+	}
+
+	for _, snapshot := range pruneSelect(snapshots, tags, policy) {
+		cmd, err := b.command("delete", repository+"::"+snapshot.ID)
+		if err != nil {
+			return err
+		}
+
+		err = cmd.Run()
+		if err != nil {
+			return err
+		}
+	}
+	return
+}
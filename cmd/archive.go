@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	proj "github.com/IanS5/go-proj"
+	"github.com/pkg/errors"
+)
+
+var archiveToBackup = false
+var archiveBackupRepo = ""
+var archiveDiscardIgnored = false
+
+var cmdArchive = makeProjectAction("archive",
+	"Upload or back up a project, then remove it, it's still listed and can be unarchived when it's needed again",
+	func(repo *proj.ProjectRepository, project string) error {
+		if archiveToBackup || archiveBackupRepo != "" {
+			backupRepoName = archiveBackupRepo
+			bs, from, err := backupRepository()
+			if err != nil {
+				return err
+			}
+			return repo.ArchiveToBackup(project, bs, from)
+		}
+
+		remote := projectRemote(repo, project)
+		if remote == "" {
+			return errors.New("the project doesn't have a remote, give one with --service or archive it to a backup with --backup")
+		}
+
+		s, err := openRemote(remote)
+		if err != nil {
+			return err
+		}
+		err = repo.ArchiveToRemote(project, remote, s, archiveDiscardIgnored)
+		if errors.Cause(err) == proj.ErrArchiveIgnoredFiles {
+			return errors.WithMessage(err, "archive it to a backup with --backup, or lose them with --discard-ignored")
+		}
+		return err
+	})
+
+var cmdUnarchive = makeProjectAction("unarchive",
+	"Restore an archived project from wherever it was archived to",
+	func(repo *proj.ProjectRepository, project string) error {
+		a, err := repo.Archived(project)
+		if err != nil {
+			return err
+		} else if a == nil {
+			return proj.ErrNotArchived
+		}
+
+		if a.Remote != "" {
+			s, err := openRemote(a.Remote)
+			if err != nil {
+				return err
+			}
+			return repo.UnarchiveFromRemote(project, s)
+		}
+
+		backupRepoName = a.BackupRepo
+		bs, _, err := backupRepository()
+		if err != nil {
+			return err
+		}
+		return repo.UnarchiveFromBackup(project, bs)
+	})
+
+func init() {
+	cmdArchive.Aliases = nil
+	cmdUnarchive.Aliases = nil
+
+	flags := cmdArchive.PersistentFlags()
+	flags.StringVarP(&storageServiceName, "service", "s", "", "The remote to upload the project to, instead of its own remote")
+	flags.BoolVarP(&archiveToBackup, "backup", "b", false, "Back the project up instead of uploading it")
+	flags.StringVar(&archiveBackupRepo, "backup-repo", "", "The backup repository to back the project up to, or the first repository if this flag is omitted")
+	flags.BoolVar(&archiveDiscardIgnored, "discard-ignored", false, "Archive the project even though the files its .gitignore ignores won't be uploaded")
+}
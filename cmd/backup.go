@@ -0,0 +1,449 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	proj "github.com/IanS5/go-proj"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var backupRepoName = ""
+var restoreSnapshot = ""
+var restoreAt = ""
+var restoreTarget = ""
+
+var timestampLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseTimestamp parses a timestamp given on the command line, timestamps without a zone are in local time
+func parseTimestamp(timestamp string) (t time.Time, err error) {
+	for _, layout := range timestampLayouts {
+		t, err = time.ParseInLocation(layout, timestamp, time.Local)
+		if err == nil {
+			return
+		}
+	}
+	return t, errors.Errorf("invalid timestamp %q, expected a format like %q", timestamp, "2006-01-02 15:04")
+}
+
+var errNoBackupRepos = errors.New("No backup repositories added, add one with proj restic add, proj borg add or proj native add")
+
+// backupTarget is a backup service, along with its configured repositories
+type backupTarget struct {
+	service proj.BackupService
+	repos   []string
+}
+
+// backupTargets lists every backup service with at least one repository
+func backupTargets() []backupTarget {
+	targets := make([]backupTarget, 0, 3)
+	if len(config.Restic.Repositories) > 0 {
+		targets = append(targets, backupTarget{config.ResticService(), config.Restic.Repositories})
+	}
+	if len(config.Borg.Repositories) > 0 {
+		targets = append(targets, backupTarget{proj.Borg{}, config.Borg.Repositories})
+	}
+	if len(config.Native.Repositories) > 0 {
+		targets = append(targets, backupTarget{config.NativeService(), config.Native.Repositories})
+	}
+	return targets
+}
+
+// backupRepository is the repository selected with --from, or the first repository, along with its backup service
+func backupRepository() (proj.BackupService, string, error) {
+	targets := backupTargets()
+	if len(targets) == 0 {
+		return nil, "", errNoBackupRepos
+	}
+
+	if backupRepoName == "" {
+		return targets[0].service, targets[0].repos[0], nil
+	}
+
+	for _, target := range targets {
+		for _, repo := range target.repos {
+			if repo == backupRepoName {
+				return target.service, repo, nil
+			}
+		}
+	}
+	return nil, "", errors.Errorf("%q isn't a restic, borg or native backup repository", backupRepoName)
+}
+
+// projectBackupRepository is the repository selected with --from, or the repository with the project's latest
+// snapshot, along with its backup service
+func projectBackupRepository(repo *proj.ProjectRepository, project string) (proj.BackupService, string, error) {
+	if backupRepoName != "" {
+		return backupRepository()
+	}
+
+	targets := backupTargets()
+	if len(targets) == 0 {
+		return nil, "", errNoBackupRepos
+	}
+
+	var found proj.BackupService
+	var foundRepo string
+	var latest time.Time
+	for _, target := range targets {
+		for _, backupRepo := range target.repos {
+			snapshots, err := repo.Snapshots(target.service, project, backupRepo)
+			if err != nil {
+				logrus.WithField("Backups", backupRepo).WithError(err).Warn("Failed to list snapshots")
+				continue
+			}
+
+			if len(snapshots) > 0 && (found == nil || snapshots[len(snapshots)-1].Time.After(latest)) {
+				found, foundRepo, latest = target.service, backupRepo, snapshots[len(snapshots)-1].Time
+			}
+		}
+	}
+
+	if found == nil {
+		return nil, "", errors.WithMessage(proj.ErrNoSuchSnapshot, "no backup repository has a snapshot of "+project)
+	}
+	return found, foundRepo, nil
+}
+
+// backedUpProjects lists the projects with snapshots in the repository selected with --from, or in any backup
+// repository, including projects that no longer exist
+func backedUpProjects(repo *proj.ProjectRepository) (projects []string, err error) {
+	targets := backupTargets()
+	if backupRepoName != "" {
+		bs, from, err := backupRepository()
+		if err != nil {
+			return nil, err
+		}
+		targets = []backupTarget{{bs, []string{from}}}
+	}
+
+	seen := make(map[string]bool, 16)
+	for _, target := range targets {
+		for _, backupRepo := range target.repos {
+			names, err := repo.BackedUp(target.service, backupRepo)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, name := range names {
+				if !seen[name] {
+					seen[name] = true
+					projects = append(projects, name)
+				}
+			}
+		}
+	}
+	sort.Strings(projects)
+	return
+}
+
+// backupProject backs a project up to every backup repository
+func backupProject(repo *proj.ProjectRepository, project string) error {
+	targets := backupTargets()
+	if len(targets) == 0 {
+		return errNoBackupRepos
+	}
+
+	for _, target := range targets {
+		err := repo.Backup(target.service, project, target.repos...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var cmdBackup = makeAllProjectsAction("backup",
+	"Backup a project to every restic, borg and native backup repository",
+	backupProject)
+
+var cmdRestore = makeListedProjectsAction("restore",
+	"Restore a project from a backup, the latest one unless --snapshot or --at is given, --all restores every backed up project",
+	backedUpProjects,
+	func(repo *proj.ProjectRepository, project string) error {
+		bs, from, err := projectBackupRepository(repo, project)
+		if err != nil {
+			return err
+		}
+
+		if restoreSnapshot != "" && restoreAt != "" {
+			return errors.New("--snapshot and --at can't be used together")
+		}
+
+		snapshot := proj.SnapshotLatest
+		if restoreSnapshot != "" {
+			snapshot = restoreSnapshot
+		} else if restoreAt != "" {
+			at, err := parseTimestamp(restoreAt)
+			if err != nil {
+				return err
+			}
+
+			snapshots, err := repo.Snapshots(bs, project, from)
+			if err != nil {
+				return err
+			}
+
+			found, err := proj.SnapshotAt(snapshots, at)
+			if err != nil {
+				return errors.WithMessage(err, "no snapshot at "+at.Format(time.RFC3339))
+			}
+			snapshot = found.ID
+		}
+
+		return repo.RestoreSnapshot(bs, project, from, snapshot, restoreTarget)
+	})
+
+var cmdSnapshots = makeProjectAction("snapshots",
+	"List a project's snapshots",
+	func(repo *proj.ProjectRepository, project string) error {
+		bs, from, err := projectBackupRepository(repo, project)
+		if err != nil {
+			return err
+		}
+
+		snapshots, err := repo.Snapshots(bs, project, from)
+		if err != nil {
+			return err
+		}
+
+		for _, snapshot := range snapshots {
+			fmt.Printf("%s %s %s %s\n",
+				snapshot.ShortID,
+				snapshot.Time.Local().Format("2006-01-02 15:04:05"),
+				snapshot.Hostname,
+				strings.Join(snapshot.Tags, ","))
+		}
+		return nil
+	})
+
+var retentionClear = false
+var retentionPolicy = proj.RetentionPolicy{}
+
+var cmdBackupPrune = makeAllProjectsAction("prune",
+	"Remove a project's snapshots that aren't kept by its retention policy, from every restic, borg and native backup repository",
+	func(repo *proj.ProjectRepository, project string) error {
+		policy := config.RetentionPolicy(repo.Ids(project)...)
+		if meta, err := repo.Metadata(project); err == nil && meta.Retention != nil {
+			policy = *meta.Retention
+		}
+
+		if policy.IsZero() {
+			logrus.WithField("Project", project).Info("No retention policy, skipping")
+			return nil
+		}
+
+		targets := backupTargets()
+		if len(targets) == 0 {
+			return errNoBackupRepos
+		}
+
+		for _, target := range targets {
+			p, ok := target.service.(proj.Pruner)
+			if !ok {
+				return errors.Errorf("%T backups can't be pruned", target.service)
+			}
+
+			err := repo.Prune(p, project, policy, target.repos...)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+var cmdBackupRetention = &cobra.Command{
+	Use:   "retention [PROJECT]",
+	Short: "Show or set the global retention policy, or a project's retention policy",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		changed := retentionClear
+		for _, flag := range []string{"keep-last", "keep-daily", "keep-weekly", "keep-monthly"} {
+			changed = changed || cmd.Flags().Changed(flag)
+		}
+
+		if len(args) == 0 {
+			if changed {
+				config.Restic.Retention = retentionPolicy
+				config.Write()
+			}
+			fmt.Println(config.Restic.Retention)
+			return
+		}
+
+		repoName, _ := cmd.Flags().GetString("repo")
+		if repoName == "" {
+			repoName = config.PrimaryRepo
+		}
+
+		repoPath, exists := config.ProjectRepositories[repoName]
+		if !exists {
+			logrus.WithField("Repo", repoName).Fatal("repository does not exist")
+		}
+
+		ids := proj.NewLocal(repoName, repoPath).Ids(args[0])
+		if changed {
+			if config.Restic.ProjectRetention == nil {
+				config.Restic.ProjectRetention = make(map[string]proj.RetentionPolicy)
+			}
+
+			// the policy is kept under the project's persistent id, replacing one set under its old id
+			for _, id := range ids {
+				delete(config.Restic.ProjectRetention, id)
+			}
+
+			if !retentionClear {
+				config.Restic.ProjectRetention[ids[0]] = retentionPolicy
+			}
+			config.Write()
+		}
+		fmt.Println(config.RetentionPolicy(ids...))
+	},
+}
+
+func init() {
+	// "r" is already taken by remove
+	cmdRestore.Aliases = []string{"rs"}
+	cmdRestore.PersistentFlags().StringVarP(&backupRepoName, "from", "f", "", "The backup repository to restore from, or the one with the latest snapshot if this flag is omitted")
+	cmdRestore.PersistentFlags().StringVar(&restoreSnapshot, "snapshot", "", "ID of the snapshot to restore")
+	cmdRestore.PersistentFlags().StringVar(&restoreAt, "at", "", "Restore the last snapshot taken at or before this time")
+	cmdRestore.PersistentFlags().StringVarP(&restoreTarget, "target", "t", "", "Restore into this folder instead of the project's folder")
+	cmdRestore.PreRun = func(cmd *cobra.Command, args []string) {
+		all, _ := cmd.Flags().GetBool("all")
+		if !all {
+			return
+		}
+
+		// every project would be restored to the same folder, or from another project's snapshot
+		for _, flag := range []string{"target", "snapshot", "at"} {
+			if cmd.Flags().Changed(flag) {
+				logrus.Fatalf("--%s can't be used with --all", flag)
+			}
+		}
+	}
+	cmdSnapshots.PersistentFlags().StringVarP(&backupRepoName, "from", "f", "", "The backup repository to list, or the one with the latest snapshot if this flag is omitted")
+	cmdBackupVerify.PersistentFlags().StringVarP(&backupRepoName, "from", "f", "", "The backup repository to verify, or the one with the latest snapshot if this flag is omitted")
+
+	cmdBackupRetention.PersistentFlags().StringP("repo", "r", "", "Repo where the project is located, or the primary repo if this flag is omitted")
+	cmdBackupRetention.PersistentFlags().IntVar(&retentionPolicy.KeepLast, "keep-last", 0, "Keep the last N snapshots")
+	cmdBackupRetention.PersistentFlags().IntVar(&retentionPolicy.KeepDaily, "keep-daily", 0, "Keep the last snapshot of each of the last N days")
+	cmdBackupRetention.PersistentFlags().IntVar(&retentionPolicy.KeepWeekly, "keep-weekly", 0, "Keep the last snapshot of each of the last N weeks")
+	cmdBackupRetention.PersistentFlags().IntVar(&retentionPolicy.KeepMonthly, "keep-monthly", 0, "Keep the last snapshot of each of the last N months")
+	cmdBackupRetention.PersistentFlags().BoolVar(&retentionClear, "clear", false, "Remove the project's own policy, so the global policy is used")
+
+	// a project named like one of these is backed up with proj backup -- NAME
+	cmdBackup.AddCommand(cmdBackupPrune, cmdBackupRetention, cmdBackupVerify, cmdBackupStatus)
+}
+
+var errBackupVerificationFailed = errors.New("Backup doesn't match the project")
+
+// formatAge formats a duration roughly, e.g. 3d4h, 5h12m, 40s
+func formatAge(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd%dh", int(d.Hours())/24, int(d.Hours())%24)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	default:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+}
+
+var cmdBackupVerify = makeAllProjectsAction("verify",
+	"Restore a project's latest backup into a temporary folder, and compare it to the project",
+	func(repo *proj.ProjectRepository, project string) error {
+		bs, from, err := projectBackupRepository(repo, project)
+		if err != nil {
+			return err
+		}
+
+		result, err := repo.VerifyBackup(bs, project, from)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s snapshot %s from %s, %d files match\n",
+			project, result.Snapshot.ShortID, result.Snapshot.Time.Local().Format("2006-01-02 15:04:05"), result.Matched)
+
+		for _, diff := range []struct {
+			label string
+			files []string
+		}{
+			{"missing from backup", result.Missing},
+			{"content differs", result.Mismatched},
+			{"changed since snapshot", result.Changed},
+			{"deleted since snapshot", result.Extra},
+		} {
+			for _, file := range diff.files {
+				fmt.Printf("  %s: %s\n", diff.label, file)
+			}
+		}
+
+		if !result.OK() {
+			return errBackupVerificationFailed
+		}
+		return nil
+	})
+
+var cmdBackupStatus = &cobra.Command{
+	Use:   "status",
+	Short: "Show the age of every project's last snapshot in every backup repository, and flag projects without one",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targets := backupTargets()
+		if len(targets) == 0 {
+			logrus.Fatal(errNoBackupRepos)
+		}
+
+		missing := 0
+		for repoName, repoPath := range config.ProjectRepositories {
+			repo := proj.NewLocal(repoName, repoPath)
+			projects, err := repo.List()
+			if err != nil {
+				logrus.WithField("Repo", repoName).Fatal(err)
+			}
+
+			for _, project := range projects {
+				for _, target := range targets {
+					for _, backupRepo := range target.repos {
+						snapshots, err := repo.Snapshots(target.service, project, backupRepo)
+						if err != nil {
+							logrus.
+								WithField("Project", project).
+								WithField("Backups", backupRepo).
+								WithError(err).
+								Error("Failed to list snapshots")
+							missing++
+							continue
+						}
+
+						if len(snapshots) == 0 {
+							fmt.Printf("%s %s %s NONE\n", repoName, project, backupRepo)
+							missing++
+							continue
+						}
+
+						last := snapshots[len(snapshots)-1]
+						fmt.Printf("%s %s %s %s\n", repoName, project, backupRepo, formatAge(time.Since(last.Time)))
+					}
+				}
+			}
+		}
+
+		if missing > 0 {
+			logrus.Fatalf("%d project backup(s) missing", missing)
+		}
+	},
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var cmdBorgAdd = &cobra.Command{
+	Use:   "add REPO",
+	Short: "Add a new borg repo",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		config.Borg.Repositories = append(config.Borg.Repositories, args[0])
+		config.Write()
+	},
+}
+
+var cmdBorgList = &cobra.Command{
+	Use:   "list",
+	Short: "List borg repositories",
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, name := range config.Borg.Repositories {
+			fmt.Println(name)
+		}
+	},
+}
+
+var cmdBorgRemove = &cobra.Command{
+	Use:   "remove REPO",
+	Short: "Remove a borg repo",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		idx := -1
+		for i, repo := range config.Borg.Repositories {
+			if repo == args[0] {
+				idx = i
+				logrus.WithField("Index", idx).Debug("Found repo")
+			}
+		}
+
+		if idx == -1 {
+			logrus.Fatal("Repo not found")
+		}
+
+		config.Borg.Repositories =
+			append(config.Borg.Repositories[:idx], config.Borg.Repositories[idx+1:]...)
+
+		config.Write()
+	},
+}
+
+var cmdBorg = &cobra.Command{
+	Use:   "borg",
+	Short: "Manage borg repositories",
+}
+
+func init() {
+	cmdBorg.AddCommand(cmdBorgAdd, cmdBorgRemove, cmdBorgList)
+}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/url"
 
+	proj "github.com/IanS5/go-proj"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -108,27 +109,49 @@ func dropboxLogin(appKey string, appSecret string) (token string, err error) {
 	return response.Token, err
 }
 
+var dropboxRemoteName = proj.RemoteTypeDropbox
+
+// dropboxRemote finds the dropbox remote selected with --remote, the default "dropbox" remote is created on demand
+func dropboxRemote() *proj.Remote {
+	remote, err := config.Remote(dropboxRemoteName)
+	if err == proj.ErrNoSuchRemote && dropboxRemoteName == proj.RemoteTypeDropbox {
+		remote = &proj.Remote{Type: proj.RemoteTypeDropbox}
+		config.Remotes[dropboxRemoteName] = remote
+	} else if err != nil {
+		logrus.WithField("Remote", dropboxRemoteName).Fatal(err)
+	}
+
+	if remote.Type != proj.RemoteTypeDropbox {
+		logrus.
+			WithField("Remote", dropboxRemoteName).
+			WithField("Type", remote.Type).
+			Fatal("not a dropbox remote")
+	}
+	return remote
+}
+
 var cmdDropbox = &cobra.Command{
 	Use:   "dropbox",
-	Short: "Manage your the dropbox account associated with proj",
+	Short: "Manage your the dropbox accounts associated with proj",
 }
 
 var cmdDropboxLogout = &cobra.Command{
 	Use:   "logout",
 	Short: "Logout of the dropbox account associated with proj",
 	Run: func(cmd *cobra.Command, args []string) {
+		remote := dropboxRemote()
 		req, err := http.NewRequest("POST", "https://api.dropboxapi.com/2/auth/token/revoke", nil)
 		if err != nil {
 			return
 		}
-		req.Header.Add("Authorization", "Bearer "+config.Dropbox.Token)
+		req.Header.Add("Authorization", "Bearer "+remote.Token)
 		_, err = http.DefaultClient.Do(req)
 
 		if err != nil {
 			logrus.WithError(err).Fatal("Logout failed")
 		}
 
-		config.Dropbox.Token = ""
+		remote.Token = ""
 		config.Write()
 		return
 	},
@@ -138,21 +161,22 @@ var cmdDropboxLogin = &cobra.Command{
 	Use:   "login",
 	Short: "Login to your dropbox account",
 	Run: func(cmd *cobra.Command, args []string) {
-		if config.Dropbox.AppKey == "" && config.Dropbox.AppSecret == "" {
+		remote := dropboxRemote()
+		if remote.AppKey == "" && remote.AppSecret == "" {
 			logrus.Fatal("Missing app key and secret, set them with proj dropbox app KEY SECRET")
-		} else if config.Dropbox.AppKey == "" {
+		} else if remote.AppKey == "" {
 			logrus.Fatal("Missing app key")
-		} else if config.Dropbox.AppSecret == "" {
+		} else if remote.AppSecret == "" {
 			logrus.Fatal("Missing app secret")
 		}
 
-		token, err := dropboxLogin(config.Dropbox.AppKey, config.Dropbox.AppSecret)
+		token, err := dropboxLogin(remote.AppKey, remote.AppSecret)
 
 		if err != nil {
 			logrus.WithError(err).Fatal("Login failed")
 		}
 
-		config.Dropbox.Token = token
+		remote.Token = token
 		config.Write()
 	},
 }
@@ -162,13 +186,15 @@ var cmdDropboxApp = &cobra.Command{
 	Short: "Add your dropbox application credentials",
 	Args:  cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
-		config.Dropbox.AppKey = args[0]
-		config.Dropbox.AppSecret = args[1]
+		remote := dropboxRemote()
+		remote.AppKey = args[0]
+		remote.AppSecret = args[1]
 
 		config.Write()
 	},
 }
 
 func init() {
+	cmdDropbox.PersistentFlags().StringVarP(&dropboxRemoteName, "remote", "R", proj.RemoteTypeDropbox, "The dropbox remote to manage")
 	cmdDropbox.AddCommand(cmdDropboxLogout, cmdDropboxLogin, cmdDropboxApp)
 }
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	proj "github.com/IanS5/go-proj"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var execFilters = []string{}
+var execTags = []string{}
+var execParallel = 1
+
+// prefixWriter prefixes every line written to it, whole lines are written at once so the output of commands
+// running in parallel doesn't get mixed up mid-line
+type prefixWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    bytes.Buffer
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+
+		line := w.buf.Next(i + 1)
+		w.mu.Lock()
+		_, err := fmt.Fprintf(w.out, "%s%s", w.prefix, line)
+		w.mu.Unlock()
+		if err != nil {
+			return len(p), err
+		}
+	}
+}
+
+// Flush writes the last line, if it didn't end with a newline
+func (w *prefixWriter) Flush() {
+	if w.buf.Len() > 0 {
+		w.Write([]byte{'\n'})
+	}
+}
+
+type execResult struct {
+	label string
+	code  int
+	err   error
+}
+
+// exitCode is the exit code of a command, or -1 if it couldn't be run at all
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	if exitErr, ok := errors.Cause(err).(*exec.ExitError); ok {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+var cmdExec = &cobra.Command{
+	Use:   "exec [--filter QUERY] [--parallel N] -- COMMAND [ARGS...]",
+	Short: "Run a command in every project matching a query",
+	Long: `Run a command in every project matching a query, inside the project's folder and with the environment
+visiting it sets (hooks aren't run). Every line of output is prefixed by the project's name, and the exit code of
+each project is summarized at the end, proj exec fails if the command failed in any project. The query uses the
+same terms as proj list, e.g.
+
+  proj exec --filter 'tag:go' --parallel 4 -- go mod tidy`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if execParallel < 1 {
+			logrus.Fatal("--parallel must be at least 1")
+		}
+
+		q, err := proj.ParseQuery(execFilters...)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		repos := allRepositories()
+		if repoName, _ := cmd.Flags().GetString("repo"); repoName != "" {
+			repos = []*proj.ProjectRepository{projectRepository(repoName)}
+		}
+
+		projects := []*proj.ProjectStat{}
+		for _, repo := range repos {
+			matches, err := repo.Query(q)
+			if err != nil {
+				logrus.WithField("Repo", repo.Name()).Fatal(err)
+			}
+
+			for _, p := range matches {
+				meta, err := p.Metadata()
+				if err != nil {
+					logrus.WithField("Repo", repo.Name()).WithField("Project", p.Name).Fatal(err)
+				}
+
+				if meta.HasTags(execTags...) {
+					projects = append(projects, p)
+				}
+			}
+		}
+
+		if len(projects) == 0 {
+			logrus.Fatal("No projects match")
+		}
+
+		sort.Slice(projects, func(i, j int) bool {
+			if projects[i].Name != projects[j].Name {
+				return projects[i].Name < projects[j].Name
+			}
+			return projects[i].Repo.Name() < projects[j].Repo.Name()
+		})
+
+		// projects are only labelled with their repository if they'd be ambiguous otherwise
+		labels := make([]string, len(projects))
+		width := 0
+		for i, p := range projects {
+			labels[i] = p.Name
+			if (i > 0 && projects[i-1].Name == p.Name) || (i+1 < len(projects) && projects[i+1].Name == p.Name) {
+				labels[i] = p.Repo.Name() + "/" + p.Name
+			}
+
+			if len(labels[i]) > width {
+				width = len(labels[i])
+			}
+		}
+
+		out := &sync.Mutex{}
+		results := make([]execResult, len(projects))
+		slots := make(chan struct{}, execParallel)
+		wg := sync.WaitGroup{}
+		for i, p := range projects {
+			slots <- struct{}{}
+			wg.Add(1)
+			go func(i int, p *proj.ProjectStat) {
+				defer func() {
+					<-slots
+					wg.Done()
+				}()
+
+				prefix := fmt.Sprintf("%-*s | ", width, labels[i])
+				stdout := &prefixWriter{mu: out, out: os.Stdout, prefix: prefix}
+				stderr := &prefixWriter{mu: out, out: os.Stderr, prefix: prefix}
+
+				err := p.Repo.Exec(p.Name, args, stdout, stderr)
+				stdout.Flush()
+				stderr.Flush()
+				results[i] = execResult{label: labels[i], code: exitCode(err), err: err}
+			}(i, p)
+		}
+		wg.Wait()
+
+		failed := 0
+		w := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(os.Stderr)
+		for _, result := range results {
+			switch {
+			case result.code == 0:
+				fmt.Fprintf(w, "%s\tok\n", result.label)
+			case result.code > 0:
+				failed++
+				fmt.Fprintf(w, "%s\texit %d\n", result.label, result.code)
+			default:
+				failed++
+				fmt.Fprintf(w, "%s\t%v\n", result.label, result.err)
+			}
+		}
+		w.Flush()
+
+		fmt.Fprintf(os.Stderr, "%d succeeded, %d failed: %s\n", len(results)-failed, failed, strings.Join(args, " "))
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	flags := cmdExec.Flags()
+	flags.SetInterspersed(false)
+	flags.StringArrayVarP(&execFilters, "filter", "F", nil, "Only run in projects matching this query, may be repeated")
+	flags.StringArrayVarP(&execTags, "tag", "T", nil, "Only run in projects with this tag, may be repeated")
+	flags.IntVarP(&execParallel, "parallel", "j", 1, "Run in this many projects at once")
+	flags.StringP("repo", "r", "", "Only run in projects in this repository, instead of every repository")
+}
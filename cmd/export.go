@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+
+	proj "github.com/IanS5/go-proj"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var exportOutput = ""
+var importAs = ""
+var importRepo = ""
+
+var cmdExport = makeProjectAction("export",
+	"Pack a project, its metadata and shell history into a portable archive (.tar.zst, .tar.gz or .tar)",
+	func(repo *proj.ProjectRepository, project string) error {
+		output := exportOutput
+		if output == "" {
+			output = project + ".tar.zst"
+		}
+
+		err := repo.Export(project, output)
+		if err == nil {
+			logrus.Infof("Exported %s to %s", project, output)
+		}
+		return err
+	})
+
+var cmdImport = &cobra.Command{
+	Use:     "import ARCHIVE",
+	Short:   "Unpack a project exported with proj export into a repository",
+	Args:    cobra.ExactArgs(1),
+	Aliases: []string{"i"},
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := importRepo
+		if repo == "" {
+			repo = config.PrimaryRepo
+		}
+
+		repoPath, exists := config.ProjectRepositories[repo]
+		if !exists {
+			logrus.WithField("Repo", repo).Fatal("repository does not exist")
+		}
+
+		project, err := proj.NewInteractiveLocal(repo, repoPath).Import(args[0], importAs)
+		if err != nil {
+			logrus.WithField("Archive", args[0]).Fatal(err)
+		}
+
+		fmt.Printf("%s %s\n", repo, project)
+	},
+}
+
+func init() {
+	cmdExport.PersistentFlags().StringVarP(&exportOutput, "output", "o", "", "The archive to create, PROJECT.tar.zst if this flag is omitted")
+	cmdImport.PersistentFlags().StringVar(&importAs, "as", "", "Import the project under a different name")
+	cmdImport.PersistentFlags().StringVarP(&importRepo, "repo", "r", "", "Repo to import the project into, or the primary repo if this flag is omitted")
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	proj "github.com/IanS5/go-proj"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var recentCount = 10
+var recentShowScore = false
+var jumpList = false
+
+// listOutput is where commands that can be run by the shell integration print to, the shell runs whatever's
+// written to stdout
+func listOutput() io.Writer {
+	if integratedShell() != "" {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+func allRepositories() []*proj.ProjectRepository {
+	repos := make([]*proj.ProjectRepository, 0, len(config.ProjectRepositories))
+	for name, path := range config.ProjectRepositories {
+		repos = append(repos, proj.NewInteractiveLocal(name, path))
+	}
+	return repos
+}
+
+var cmdRecent = &cobra.Command{
+	Use:   "recent",
+	Short: "List the projects you visit most, and most recently",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		frecency, err := proj.LoadFrecency()
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		now := time.Now()
+		shown := 0
+		for _, v := range frecency.Ranked(now) {
+			if recentCount > 0 && shown >= recentCount {
+				break
+			}
+
+			repoPath, exists := config.ProjectRepositories[v.Repo]
+			if !exists {
+				continue
+			}
+
+			if _, err := os.Stat(proj.NewLocal(v.Repo, repoPath).Path(v.Name)); err != nil {
+				continue
+			}
+
+			if recentShowScore {
+				fmt.Printf("%8.1f ", v.Score(now))
+			}
+
+			if showRepoList {
+				fmt.Printf("%s %s\n", v.Repo, v.Name)
+			} else {
+				fmt.Println(v.Name)
+			}
+			shown++
+		}
+	},
+}
+
+var cmdJump = &cobra.Command{
+	Use:   "jump QUERY...",
+	Short: "Visit the best project matching a fuzzy query, ranked by how often and how recently you visited it",
+	Long: `Visit the best project matching a fuzzy query, ranked by how often and how recently you visited it.
+Every word of the query has to match the project's name, in order but not necessarily together (e.g. gpj matches
+go-proj), words with a / are matched against REPO/NAME. A project named exactly QUERY is always visited first.`,
+	Aliases: []string{"j"},
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		frecency, err := proj.LoadFrecency()
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		candidates, err := frecency.Jump(allRepositories(), args)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		if jumpList {
+			out := listOutput()
+			for _, c := range candidates {
+				fmt.Fprintf(out, "%8.1f %s %s\n", c.Score, c.Repo.Name(), c.Name)
+			}
+			return
+		}
+
+		if len(candidates) == 0 {
+			logrus.WithField("Query", args).Fatal("no project matches")
+		}
+
+		best := candidates[0]
+		logrus.Debugf("Jumping to %s in %s", best.Name, best.Repo.Name())
+		err = visitProject(best.Repo, best.Name)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	cmdRecent.PersistentFlags().IntVarP(&recentCount, "count", "n", 10, "Number of projects to show, or 0 to show every visited project")
+	cmdRecent.PersistentFlags().BoolVarP(&recentShowScore, "score", "s", false, "Show each project's frecency score")
+	cmdRecent.PersistentFlags().BoolVarP(&showRepoList, "show-repo", "w", false, "Show the repo each project comes from")
+	cmdJump.PersistentFlags().BoolVarP(&jumpList, "list", "l", false, "List the matching projects, best first, instead of visiting one")
+}
@@ -0,0 +1,371 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	proj "github.com/IanS5/go-proj"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var showRepoList = false
+var listTags = []string{}
+var listSort = "name"
+var listReverse = false
+var listFormat = "plain"
+var listTemplate = ""
+var listSync = false
+var listHideArchived = false
+var listArchived = false
+
+const noRemote = "no-remote"
+
+// archivedStatus is shown instead of the sync status of archived projects
+const archivedStatus = "archived"
+
+// listRow is everything proj list can show about a project
+type listRow struct {
+	Repo        string    `json:"repo"`
+	Name        string    `json:"name"`
+	Path        string    `json:"path"`
+	Description string    `json:"description,omitempty"`
+	Tags        []string  `json:"tags"`
+	Size        int64     `json:"size"`
+	Modified    time.Time `json:"modified"`
+	Visited     time.Time `json:"visited"`
+	Branch      string    `json:"branch,omitempty"`
+	Dirty       bool      `json:"dirty"`
+	Sync        string    `json:"sync,omitempty"`
+	Archived    bool      `json:"archived"`
+}
+
+// formatSize formats a number of bytes for people, e.g. 512B, 1.5K, 20M
+func formatSize(size int64) string {
+	units := "KMGTPE"
+	if size < 1024 {
+		return fmt.Sprintf("%dB", size)
+	}
+
+	f := float64(size)
+	unit := -1
+	for f >= 1024 && unit < len(units)-1 {
+		f /= 1024
+		unit++
+	}
+
+	if f < 10 {
+		return fmt.Sprintf("%.1f%c", f, units[unit])
+	}
+	return fmt.Sprintf("%.0f%c", f, units[unit])
+}
+
+func formatListTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// makeListRow works out everything proj list shows about a project, remotes caches the remotes that were opened
+func makeListRow(p *proj.ProjectStat, frecency proj.Frecency, remotes map[string]proj.StorageService) (row *listRow, err error) {
+	meta, err := p.Metadata()
+	if err != nil {
+		return
+	}
+
+	size, err := p.Size()
+	if err != nil {
+		return
+	}
+
+	modified, err := p.ModTime()
+	if err != nil {
+		return
+	}
+
+	branch, dirty, err := proj.GitStatus(p.Path())
+	if err != nil {
+		return
+	}
+
+	row = &listRow{
+		Repo:        p.Repo.Name(),
+		Name:        p.Name,
+		Path:        p.Path(),
+		Description: meta.Description,
+		Tags:        meta.Tags,
+		Size:        size,
+		Modified:    modified,
+		Visited:     frecency.Get(p.Repo.Name(), p.Name).Last,
+		Branch:      branch,
+		Dirty:       dirty,
+	}
+
+	if row.Tags == nil {
+		row.Tags = []string{}
+	}
+
+	if p.Archive != nil {
+		row.Archived = true
+		if listSync {
+			row.Sync = archivedStatus
+		}
+		return
+	}
+
+	if !listSync {
+		return
+	}
+
+	remote := storageServiceName
+	if remote == "" {
+		remote = meta.Remote
+	}
+
+	if remote == "" {
+		row.Sync = noRemote
+		return
+	}
+
+	s, opened := remotes[remote]
+	if !opened {
+		s, err = openRemote(remote)
+		if err != nil {
+			return
+		}
+		remotes[remote] = s
+	}
+
+	row.Sync, err = p.Repo.SyncStatus(p.Name, s)
+	return
+}
+
+func printListTable(rows []*listRow) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	header := "REPO\tNAME\tSIZE\tMODIFIED\tVISITED\tBRANCH"
+	if listSync {
+		header += "\tSYNC"
+	}
+	fmt.Fprintln(w, header+"\tPATH")
+
+	for _, row := range rows {
+		branch := row.Branch
+		if branch == "" {
+			branch = "-"
+		} else if row.Dirty {
+			branch += "*"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s",
+			row.Repo, row.Name, formatSize(row.Size), formatListTime(row.Modified), formatListTime(row.Visited), branch)
+		if listSync {
+			fmt.Fprintf(w, "\t%s", row.Sync)
+		}
+
+		if row.Archived {
+			fmt.Fprintf(w, "\t(%s)\n", archivedStatus)
+		} else {
+			fmt.Fprintf(w, "\t%s\n", row.Path)
+		}
+	}
+	w.Flush()
+}
+
+func printListCSV(rows []*listRow) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"repo", "name", "path", "description", "tags", "size", "modified", "visited", "branch", "dirty", "sync", "archived"})
+	for _, row := range rows {
+		visited := ""
+		if !row.Visited.IsZero() {
+			visited = row.Visited.Format(time.RFC3339)
+		}
+
+		w.Write([]string{
+			row.Repo,
+			row.Name,
+			row.Path,
+			row.Description,
+			strings.Join(row.Tags, ","),
+			strconv.FormatInt(row.Size, 10),
+			row.Modified.Format(time.RFC3339),
+			visited,
+			row.Branch,
+			strconv.FormatBool(row.Dirty),
+			row.Sync,
+			strconv.FormatBool(row.Archived),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+var listTemplateFuncs = template.FuncMap{
+	"size": formatSize,
+	"time": formatListTime,
+	"join": strings.Join,
+}
+
+func printListTemplate(rows []*listRow) error {
+	if listTemplate == "" {
+		return errors.New("--format template needs a --template")
+	}
+
+	tmpl, err := template.New("list").Funcs(listTemplateFuncs).Parse(listTemplate)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		err = tmpl.Execute(os.Stdout, row)
+		if err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+var cmdList = &cobra.Command{
+	Use:   "list QUERY...",
+	Short: "List projects matching a query",
+	Long: `List projects matching a query, terms are separated by spaces, alternatives by OR, a term is
+  REGEX           the name matches a regular expression
+  re:REGEX        the same, for regular expressions that look like another kind of term
+  glob:PATTERN    the name matches a shell pattern, e.g. glob:go-*
+  fuzzy:CHARS     the name contains CHARS in order, ignoring case
+  tag:TAG         the project is tagged with TAG
+  mtime:FROM..TO  the project was modified between FROM and TO, each is a date (2006-01-02) or an
+                  age (90m, 12h, 7d, 2w), either can be left out, mtime:7d is short for mtime:7d..
+and any term can be negated with a leading !, e.g.
+
+  proj list 'tag:go mtime:30d OR glob:*-service !old'
+
+archived projects are listed too, as they were when they were archived, unless --no-archived is given, every
+format but plain shows which projects are archived. The plain format, whose names are often passed on to other
+commands, leaves archived projects out unless --archived is given.
+
+--format template runs a Go template for each project, with the fields
+  .Repo .Name .Path .Description .Tags .Size .Modified .Visited .Branch .Dirty .Sync .Archived
+and the functions size, time and join, e.g.
+
+  proj list --format template --template '{{.Name}} {{size .Size}} {{join .Tags ","}}'`,
+	Aliases: []string{"l"},
+	Run: func(cmd *cobra.Command, args []string) {
+		q, err := proj.ParseQuery(args...)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		if listArchived && listHideArchived {
+			logrus.Fatal("--archived and --no-archived can't be used together")
+		}
+
+		hideArchived := listHideArchived || (listFormat == "plain" && !listArchived)
+		projects := []*proj.ProjectStat{}
+		for name, path := range config.ProjectRepositories {
+			repo := proj.NewLocal(name, path)
+			matches, err := repo.Query(q)
+			if err != nil {
+				logrus.WithField("Repo", name).Fatal(err)
+			}
+
+			if !hideArchived {
+				archived, err := repo.QueryArchived(q)
+				if err != nil {
+					logrus.WithField("Repo", name).Fatal(err)
+				}
+				matches = append(matches, archived...)
+			}
+
+			for _, p := range matches {
+				meta, err := p.Metadata()
+				if err != nil {
+					logrus.WithField("Repo", name).WithField("Project", p.Name).Fatal(err)
+				}
+
+				if meta.HasTags(listTags...) {
+					projects = append(projects, p)
+				}
+			}
+		}
+
+		err = proj.SortProjects(projects, listSort)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		if listReverse {
+			for i, j := 0, len(projects)-1; i < j; i, j = i+1, j-1 {
+				projects[i], projects[j] = projects[j], projects[i]
+			}
+		}
+
+		if listFormat == "plain" {
+			for _, p := range projects {
+				if showRepoList {
+					fmt.Printf("%s %s\n", p.Repo.Name(), p.Name)
+				} else {
+					fmt.Println(p.Name)
+				}
+			}
+			return
+		}
+
+		rows := make([]*listRow, 0, len(projects))
+		frecency, err := proj.LoadFrecency()
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		remotes := make(map[string]proj.StorageService)
+		for _, p := range projects {
+			row, err := makeListRow(p, frecency, remotes)
+			if err != nil {
+				logrus.WithField("Repo", p.Repo.Name()).WithField("Project", p.Name).Fatal(err)
+			}
+			rows = append(rows, row)
+		}
+
+		switch listFormat {
+		case "table":
+			printListTable(rows)
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(rows)
+		case "csv":
+			err = printListCSV(rows)
+		case "template":
+			err = printListTemplate(rows)
+		default:
+			err = errors.Errorf("unknown format %q, expected plain, table, json, csv or template", listFormat)
+		}
+
+		if err != nil {
+			logrus.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	flags := cmdList.PersistentFlags()
+	flags.BoolVarP(&showRepoList, "show-repo", "w", false, "Show the repo each project comes from")
+	flags.StringArrayVarP(&listTags, "tag", "T", nil, "Only list projects with this tag, may be repeated")
+	flags.StringVar(&listSort, "sort", "name", "Sort by "+strings.Join(proj.SortKeys, ", ")+", everything but name puts the largest or newest first")
+	flags.BoolVar(&listReverse, "reverse", false, "Reverse the sort order")
+	flags.StringVarP(&listFormat, "format", "f", "plain", "Output format, one of plain, table, json, csv or template")
+	flags.StringVar(&listTemplate, "template", "", "Go template used by --format template")
+	flags.BoolVar(&listHideArchived, "no-archived", false, "Don't list archived projects")
+	flags.BoolVar(&listArchived, "archived", false, "List archived projects in the plain format too")
+	flags.BoolVar(&listSync, "sync", false, "Compare each project to its remote, this can be slow")
+	flags.StringVarP(&storageServiceName, "service", "s", "", "The remote projects are compared to by --sync, instead of each project's own remote")
+}
@@ -0,0 +1,308 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	proj "github.com/IanS5/go-proj"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var metaDescription = ""
+var metaRemote = ""
+var metaEnv = []string{}
+var metaUnsetEnv = []string{}
+var metaRetention = proj.RetentionPolicy{}
+var metaClearRetention = false
+var tagRemove = false
+var metaEnvFiles = []string{}
+var metaPath = []string{}
+var metaOnEnter = []string{}
+var metaOnExit = []string{}
+var metaClearActivation = false
+
+// projectRepository finds a project repository by name, or the primary repository if name is empty
+func projectRepository(name string) *proj.ProjectRepository {
+	if name == "" {
+		name = config.PrimaryRepo
+	}
+
+	repoPath, exists := config.ProjectRepositories[name]
+	if !exists {
+		logrus.WithField("Repo", name).Fatal("repository does not exist")
+	}
+	return proj.NewInteractiveLocal(name, repoPath)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+var cmdMeta = &cobra.Command{
+	Use:   "meta PROJECT",
+	Short: "Show or change a project's description, remote, retention policy and environment",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		repoName, _ := cmd.Flags().GetString("repo")
+		repo := projectRepository(repoName)
+		project := args[0]
+
+		meta, err := repo.Metadata(project)
+		if err != nil {
+			logrus.WithField("Project", project).Fatal(err)
+		}
+
+		// changes made here are made by the user, so they don't need to be trusted again, unless the
+		// project wasn't trusted to begin with
+		trusted, err := repo.IsTrusted(project)
+		if err != nil {
+			logrus.WithField("Project", project).Fatal(err)
+		}
+
+		flags := cmd.Flags()
+		changed := false
+		if flags.Changed("description") {
+			meta.Description = metaDescription
+			changed = true
+		}
+
+		if flags.Changed("remote") {
+			if metaRemote != "" {
+				if _, err := config.Remote(metaRemote); err != nil {
+					logrus.WithField("Remote", metaRemote).Fatal(err)
+				}
+			}
+			meta.Remote = metaRemote
+			changed = true
+		}
+
+		for _, kv := range metaEnv {
+			i := strings.IndexByte(kv, '=')
+			if i <= 0 {
+				logrus.WithField("Env", kv).Fatal("environment variables must be given as NAME=VALUE")
+			}
+
+			if meta.Env == nil {
+				meta.Env = make(map[string]string)
+			}
+			meta.Env[kv[:i]] = kv[i+1:]
+			changed = true
+		}
+
+		for _, name := range metaUnsetEnv {
+			delete(meta.Env, name)
+			changed = true
+		}
+
+		for _, flag := range []string{"keep-last", "keep-daily", "keep-weekly", "keep-monthly"} {
+			if flags.Changed(flag) {
+				policy := metaRetention
+				meta.Retention = &policy
+				changed = true
+				break
+			}
+		}
+
+		if metaClearRetention {
+			meta.Retention = nil
+			changed = true
+		}
+
+		if metaClearActivation {
+			meta.EnvFiles = nil
+			meta.Path = nil
+			meta.OnEnter = nil
+			meta.OnExit = nil
+			changed = true
+		}
+
+		if len(metaEnvFiles)+len(metaPath)+len(metaOnEnter)+len(metaOnExit) > 0 {
+			meta.EnvFiles = append(meta.EnvFiles, metaEnvFiles...)
+			meta.Path = append(meta.Path, metaPath...)
+			meta.OnEnter = append(meta.OnEnter, metaOnEnter...)
+			meta.OnExit = append(meta.OnExit, metaOnExit...)
+			changed = true
+		}
+
+		if changed {
+			err = repo.WriteMetadata(project, meta)
+			if err == nil && trusted {
+				err = repo.TrustProject(project)
+			}
+
+			if err != nil {
+				logrus.WithField("Project", project).Fatal(err)
+			}
+		}
+
+		printMetadata(meta)
+	},
+}
+
+func printMetadata(meta *proj.Metadata) {
+	fmt.Printf("description: %s\n", meta.Description)
+	fmt.Printf("tags: %s\n", strings.Join(meta.Tags, ", "))
+	if meta.Created != nil {
+		fmt.Printf("created: %s\n", formatTime(*meta.Created))
+	}
+	fmt.Printf("remote: %s\n", meta.Remote)
+	if meta.Retention != nil {
+		fmt.Printf("retention: %s\n", meta.Retention)
+	}
+
+	names := make([]string, 0, len(meta.Env))
+	for name := range meta.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Printf("env: %s=%s\n", name, meta.Env[name])
+	}
+
+	for _, file := range meta.EnvFiles {
+		fmt.Printf("env file: %s\n", file)
+	}
+
+	for _, dir := range meta.Path {
+		fmt.Printf("path: %s\n", dir)
+	}
+
+	for _, hook := range meta.OnEnter {
+		fmt.Printf("on enter: %s\n", hook)
+	}
+
+	for _, hook := range meta.OnExit {
+		fmt.Printf("on exit: %s\n", hook)
+	}
+
+	for _, window := range meta.TmuxWindows {
+		fmt.Printf("tmux window: %s (%s)\n", window.Name, window.Layout)
+		for _, pane := range window.Panes {
+			fmt.Printf("  pane: %s\n", pane)
+		}
+	}
+}
+
+var cmdInfo = makeProjectAction("info",
+	"Show everything proj knows about a project",
+	func(repo *proj.ProjectRepository, project string) error {
+		meta, err := repo.Metadata(project)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("name: %s\n", project)
+		fmt.Printf("repo: %s\n", repo.Name())
+		fmt.Printf("path: %s\n", repo.Path(project))
+		fmt.Printf("id: %s\n", repo.Id(project))
+		printMetadata(meta)
+
+		frecency, err := proj.LoadFrecency()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("last visited: %s\n", formatTime(frecency.Get(repo.Name(), project).Last))
+
+		if meta.Retention == nil {
+			fmt.Printf("retention: %s\n", config.RetentionPolicy(repo.Ids(project)...))
+		}
+
+		trusted, err := repo.IsTrusted(project)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("trusted: %t\n", trusted)
+		return nil
+	})
+
+var cmdTrust = makeProjectAction("trust",
+	"Allow visiting a project to set its environment and run its hooks",
+	func(repo *proj.ProjectRepository, project string) error {
+		meta, err := repo.Metadata(project)
+		if err != nil {
+			return err
+		}
+
+		printMetadata(meta)
+		if len(meta.OnEnter) > 0 || len(meta.OnExit) > 0 || len(meta.TmuxWindows) > 0 {
+			logrus.Warn("Only the files hooks name, like ./setup.sh, are trusted as they are now, files they use indirectly, like a Makefile, can change without asking again")
+		}
+
+		if !proj.Confirm("Trust the environment and hooks of %s?", project) {
+			return nil
+		}
+		return repo.TrustProject(project)
+	})
+
+var cmdUntrust = makeProjectAction("untrust",
+	"Stop visiting a project from setting its environment and running its hooks",
+	func(repo *proj.ProjectRepository, project string) error {
+		return repo.UntrustProject(project)
+	})
+
+var cmdTag = &cobra.Command{
+	Use:   "tag PROJECT [TAGS...]",
+	Short: "Tag a project, or show its tags if no tags are given",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		repoName, _ := cmd.Flags().GetString("repo")
+		repo := projectRepository(repoName)
+		project, tags := args[0], args[1:]
+
+		meta, err := repo.Metadata(project)
+		if err != nil {
+			logrus.WithField("Project", project).Fatal(err)
+		}
+
+		if len(tags) > 0 {
+			if tagRemove {
+				meta.RemoveTags(tags...)
+			} else {
+				meta.AddTags(tags...)
+			}
+
+			err = repo.WriteMetadata(project, meta)
+			if err != nil {
+				logrus.WithField("Project", project).Fatal(err)
+			}
+		}
+
+		for _, tag := range meta.Tags {
+			fmt.Println(tag)
+		}
+	},
+}
+
+func init() {
+	flags := cmdMeta.PersistentFlags()
+	flags.StringP("repo", "r", "", "Repo where the project is located, or the primary repo if this flag is omitted")
+	flags.StringVarP(&metaDescription, "description", "D", "", "Set the project's description")
+	flags.StringVar(&metaRemote, "remote", "", "Set the remote the project is uploaded to and downloaded from when --service is omitted")
+	flags.StringArrayVarP(&metaEnv, "env", "e", nil, "Set an environment variable as NAME=VALUE for when the project is visited, may be repeated")
+	flags.StringArrayVar(&metaUnsetEnv, "unset-env", nil, "Remove one of the project's environment variables, may be repeated")
+	flags.IntVar(&metaRetention.KeepLast, "keep-last", 0, "Keep the last N snapshots")
+	flags.IntVar(&metaRetention.KeepDaily, "keep-daily", 0, "Keep the last snapshot of each of the last N days")
+	flags.IntVar(&metaRetention.KeepWeekly, "keep-weekly", 0, "Keep the last snapshot of each of the last N weeks")
+	flags.IntVar(&metaRetention.KeepMonthly, "keep-monthly", 0, "Keep the last snapshot of each of the last N months")
+	flags.StringArrayVar(&metaEnvFiles, "env-file", nil, "Load a .env file, relative to the project, when the project is visited, may be repeated")
+	flags.StringArrayVar(&metaPath, "path", nil, "Add a folder, relative to the project, to the start of PATH when the project is visited, may be repeated")
+	flags.StringArrayVar(&metaOnEnter, "on-enter", nil, "Run a shell command when the project is visited, may be repeated")
+	flags.StringArrayVar(&metaOnExit, "on-exit", nil, "Run a shell command when the project is left, may be repeated")
+	flags.BoolVar(&metaClearActivation, "clear-hooks", false, "Remove the project's env files, path folders and hooks")
+	flags.BoolVar(&metaClearRetention, "clear-retention", false, "Remove the project's own policy, so the configured policy is used")
+
+	// "i" and "u" are already taken by import and upload, and trusting a project shouldn't be a typo away
+	cmdInfo.Aliases = nil
+	cmdTrust.Aliases = nil
+	cmdUntrust.Aliases = nil
+
+	cmdTag.Flags().BoolVarP(&tagRemove, "remove", "x", false, "Remove TAGS from the project instead of adding them")
+	cmdTag.PersistentFlags().StringP("repo", "r", "", "Repo where the project is located, or the primary repo if this flag is omitted")
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"sort"
+
+	proj "github.com/IanS5/go-proj"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var moveTo = ""
+var moveName = ""
+var moveSkipRemotes = false
+
+// moveProject moves a project locally, then on every configured remote
+func moveProject(from *proj.ProjectRepository, name string, to *proj.ProjectRepository, newName string) {
+	log := logrus.WithField("Repo", from.Name()).WithField("Project", name)
+	err := from.Move(name, to, newName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if moveSkipRemotes {
+		return
+	}
+
+	remotes := make([]string, 0, len(config.Remotes))
+	for remote := range config.Remotes {
+		remotes = append(remotes, remote)
+	}
+	sort.Strings(remotes)
+
+	for _, remote := range remotes {
+		s, err := openRemote(remote)
+		if err == nil {
+			err = from.MoveRemote(name, to, newName, s)
+		}
+
+		if err != nil {
+			log.WithField("Remote", remote).Warnf("Couldn't move the remote copy: %v", err)
+		}
+	}
+}
+
+var cmdRename = &cobra.Command{
+	Use:   "rename OLD NEW",
+	Short: "Rename a project, along with its shell history and its copy on every remote",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		repoName, _ := cmd.Flags().GetString("repo")
+		repo := projectRepository(repoName)
+		moveProject(repo, args[0], repo, args[1])
+	},
+}
+
+var cmdMove = &cobra.Command{
+	Use:   "move PROJECT --to REPO",
+	Short: "Move a project to another repository, along with its shell history and its copy on every remote",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		repoName, _ := cmd.Flags().GetString("repo")
+		newName := moveName
+		if newName == "" {
+			newName = args[0]
+		}
+		moveProject(projectRepository(repoName), args[0], projectRepository(moveTo), newName)
+	},
+}
+
+func init() {
+	cmdRename.Flags().StringP("repo", "r", "", "The repository the project is in")
+	cmdRename.Flags().BoolVar(&moveSkipRemotes, "skip-remotes", false, "Don't rename the project's copies on remotes")
+
+	cmdMove.Flags().StringP("repo", "r", "", "The repository the project is in")
+	cmdMove.Flags().StringVar(&moveTo, "to", "", "The repository to move the project to")
+	cmdMove.Flags().StringVar(&moveName, "name", "", "A new name for the project, it keeps its name by default")
+	cmdMove.Flags().BoolVar(&moveSkipRemotes, "skip-remotes", false, "Don't move the project's copies on remotes")
+	cmdMove.MarkFlagRequired("to")
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var cmdNativeAdd = &cobra.Command{
+	Use:   "add REPO",
+	Short: "Add a new native backup repo, either a folder or remote:NAME to keep backups on a remote",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		config.Native.Repositories = append(config.Native.Repositories, args[0])
+		config.Write()
+	},
+}
+
+var cmdNativeList = &cobra.Command{
+	Use:   "list",
+	Short: "List native backup repositories",
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, name := range config.Native.Repositories {
+			fmt.Println(name)
+		}
+	},
+}
+
+var cmdNativeRemove = &cobra.Command{
+	Use:   "remove REPO",
+	Short: "Remove a native backup repo (this doesn't delete the backups)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		idx := -1
+		for i, repo := range config.Native.Repositories {
+			if repo == args[0] {
+				idx = i
+				logrus.WithField("Index", idx).Debug("Found repo")
+			}
+		}
+
+		if idx == -1 {
+			logrus.Fatal("Repo not found")
+		}
+
+		config.Native.Repositories =
+			append(config.Native.Repositories[:idx], config.Native.Repositories[idx+1:]...)
+
+		config.Write()
+	},
+}
+
+var cmdNativeVerify = &cobra.Command{
+	Use:   "verify [REPO]",
+	Short: "Check every chunk in a native backup repo, or in all of them, for missing or corrupt data",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		repos := config.Native.Repositories
+		if len(args) == 1 {
+			repos = args
+		}
+
+		for _, repo := range repos {
+			logrus.WithField("Repo", repo).Info("Verifying")
+			err := config.NativeService().Verify(repo)
+			if err != nil {
+				logrus.WithField("Repo", repo).Fatal(err)
+			}
+		}
+	},
+}
+
+var cmdNative = &cobra.Command{
+	Use:   "native",
+	Short: "Manage the built-in backup engine's repositories, for machines without restic",
+}
+
+func init() {
+	cmdNative.AddCommand(cmdNativeAdd, cmdNativeRemove, cmdNativeList, cmdNativeVerify)
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	proj "github.com/IanS5/go-proj"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var pickPrint = false
+
+// projectPreview describes a project for the picker
+func projectPreview(repo *proj.ProjectRepository, project string, visits *proj.Visits) func() []string {
+	return func() []string {
+		p := repo.Stat(project)
+		lines := []string{
+			"path: " + p.Path(),
+			"repo: " + repo.Name(),
+		}
+
+		if meta, err := p.Metadata(); err == nil {
+			if meta.Description != "" {
+				lines = append(lines, "description: "+meta.Description)
+			}
+
+			if len(meta.Tags) > 0 {
+				lines = append(lines, "tags: "+strings.Join(meta.Tags, ", "))
+			}
+
+			if meta.Remote != "" {
+				lines = append(lines, "remote: "+meta.Remote)
+			}
+		}
+
+		if visits.Count > 0 {
+			lines = append(lines, fmt.Sprintf("visited: %.0f times, last %s ago", visits.Count, formatAge(time.Since(visits.Last))))
+		} else {
+			lines = append(lines, "visited: never")
+		}
+
+		if modified, err := p.ModTime(); err == nil {
+			lines = append(lines, fmt.Sprintf("modified: %s ago", formatAge(time.Since(modified))))
+		}
+
+		if size, err := p.Size(); err == nil {
+			lines = append(lines, "size: "+formatSize(size))
+		}
+
+		if branch, dirty, err := proj.GitStatus(p.Path()); err == nil && branch != "" {
+			if dirty {
+				branch += " (uncommitted changes)"
+			}
+			lines = append(lines, "git: "+branch)
+		}
+		return lines
+	}
+}
+
+// pickProject lets the user pick a project from every repository, or only from repos, projects are listed with the
+// ones visited most, and most recently first
+func pickProject(repos []*proj.ProjectRepository) (*proj.ProjectRepository, string, error) {
+	frecency, err := proj.LoadFrecency()
+	if err != nil {
+		return nil, "", err
+	}
+
+	type candidate struct {
+		repo    *proj.ProjectRepository
+		project string
+		visits  *proj.Visits
+	}
+
+	candidates := []candidate{}
+	for _, repo := range repos {
+		projects, err := repo.List()
+		if err != nil {
+			return nil, "", err
+		}
+
+		for _, project := range projects {
+			candidates = append(candidates, candidate{repo, project, frecency.Get(repo.Name(), project)})
+		}
+	}
+
+	now := time.Now()
+	sort.SliceStable(candidates, func(i, j int) bool {
+		si, sj := candidates[i].visits.Score(now), candidates[j].visits.Score(now)
+		if si != sj {
+			return si > sj
+		}
+		return candidates[i].project < candidates[j].project
+	})
+
+	items := make([]proj.PickerItem, 0, len(candidates))
+	for _, c := range candidates {
+		label := c.project
+		if len(repos) > 1 {
+			label = c.repo.Name() + "/" + c.project
+		}
+
+		items = append(items, proj.PickerItem{
+			Label:   label,
+			Preview: projectPreview(c.repo, c.project, c.visits),
+		})
+	}
+
+	choice, err := proj.Pick("project", items)
+	if err != nil {
+		return nil, "", err
+	}
+	return candidates[choice].repo, candidates[choice].project, nil
+}
+
+// pickRepositories are the repositories the picker picks from, the one given with --repo, or every repository
+func pickRepositories(cmd *cobra.Command) []*proj.ProjectRepository {
+	if !cmd.Flags().Changed("repo") {
+		return allRepositories()
+	}
+
+	repoName, _ := cmd.Flags().GetString("repo")
+	return []*proj.ProjectRepository{projectRepository(repoName)}
+}
+
+var cmdPick = &cobra.Command{
+	Use:   "pick",
+	Short: "Pick a project to visit from a filterable list of every project",
+	Long: `Pick a project to visit from a filterable list of every project, type to filter the list, use the arrow
+keys (or ctrl-p and ctrl-n) to move, enter to pick a project and escape to cancel.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo, project, err := pickProject(pickRepositories(cmd))
+		if err == proj.ErrPickCancelled {
+			return
+		} else if err != nil {
+			logrus.Fatal(err)
+		}
+
+		if !pickPrint {
+			err = visitProject(repo, project)
+			if err != nil {
+				logrus.Fatal(err)
+			}
+			return
+		}
+
+		if sh := integratedShell(); sh != "" {
+			printShellScript(sh.Echo(repo.Path(project)))
+		} else {
+			fmt.Println(repo.Path(project))
+		}
+	},
+}
+
+func init() {
+	cmdPick.PersistentFlags().StringP("repo", "r", "", "Only pick from the projects in this repo")
+	cmdPick.PersistentFlags().BoolVarP(&pickPrint, "print", "p", false, "Print the project's path instead of visiting it")
+
+	// visit picks a project when it isn't given one
+	visitOne := cmdVisit.Run
+	cmdVisit.Use = "visit [PROJECT]"
+	cmdVisit.Args = cobra.MaximumNArgs(1)
+	cmdVisit.Run = func(cmd *cobra.Command, args []string) {
+		if len(args) == 1 {
+			visitOne(cmd, args)
+			return
+		}
+
+		repo, project, err := pickProject(pickRepositories(cmd))
+		if err == proj.ErrPickCancelled {
+			return
+		} else if err != nil {
+			logrus.Fatal(err)
+		}
+
+		err = visitProject(repo, project)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+
+	proj "github.com/IanS5/go-proj"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var cmdRemote = &cobra.Command{
+	Use:   "remote",
+	Short: "Manage the storage remotes projects can be uploaded to",
+}
+
+var cmdRemoteList = &cobra.Command{
+	Use:   "list",
+	Short: "List remotes, and their types",
+	Run: func(cmd *cobra.Command, args []string) {
+		for name, remote := range config.Remotes {
+			fmt.Printf("%s %s %s\n", name, remote.Type, remote.Root)
+		}
+	},
+}
+
+var remoteRoot = ""
+
+var cmdRemoteAdd = &cobra.Command{
+	Use:   "add NAME TYPE [ARGS...]",
+	Short: "Add a remote, local remotes take a PATH, dropbox remotes optionally take an app KEY and SECRET",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		remote := &proj.Remote{Type: args[1], Root: remoteRoot}
+
+		switch remote.Type {
+		case proj.RemoteTypeLocal:
+			if len(args) != 3 {
+				logrus.Fatal("local remotes take exactly one argument, PATH")
+			}
+			remote.Root = args[2]
+		case proj.RemoteTypeDropbox:
+			if len(args) == 4 {
+				remote.AppKey = args[2]
+				remote.AppSecret = args[3]
+			} else if len(args) != 2 {
+				logrus.Fatal("dropbox remotes take either no arguments, or an app KEY and SECRET")
+			}
+		default:
+			logrus.
+				WithField("Type", remote.Type).
+				WithField("Options", proj.RemoteTypes).
+				Fatal("invalid remote type")
+		}
+
+		if config.Remotes == nil {
+			config.Remotes = make(map[string]*proj.Remote)
+		}
+
+		config.Remotes[args[0]] = remote
+		config.Write()
+	},
+}
+
+var cmdRemoteRoot = &cobra.Command{
+	Use:   "root NAME ROOT",
+	Short: "Change the folder a remote stores projects in, projects are kept under ROOT/REPO/PROJECT",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		remote, err := config.Remote(args[0])
+		if err != nil {
+			logrus.WithField("Remote", args[0]).Fatal(err)
+		}
+
+		remote.Root = args[1]
+		config.Write()
+	},
+}
+
+var cmdRemoteRemove = &cobra.Command{
+	Use:   "remove NAME",
+	Short: "Remove a remote (this doesn't delete anything stored on it)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, exists := config.Remotes[args[0]]; !exists {
+			logrus.WithField("Remote", args[0]).Fatal(proj.ErrNoSuchRemote)
+		}
+
+		delete(config.Remotes, args[0])
+		config.Write()
+	},
+}
+
+func init() {
+	cmdRemoteAdd.PersistentFlags().StringVar(&remoteRoot, "root", "", "Folder on the remote where projects are stored (e.g. /Apps/proj)")
+	cmdRemote.AddCommand(cmdRemoteAdd, cmdRemoteList, cmdRemoteRoot, cmdRemoteRemove)
+}
@@ -2,17 +2,92 @@ package cmd
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
+	proj "github.com/IanS5/go-proj"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
+var resticPasswordFile = ""
+var resticPasswordCommand = ""
+var resticEnv = []string{}
+var resticUnsetEnv = []string{}
+
+// setResticOptions updates a repository's options from the flags that were given
+func setResticOptions(cmd *cobra.Command, repo string) {
+	if config.Restic.Options == nil {
+		config.Restic.Options = make(map[string]*proj.ResticOptions)
+	}
+
+	opts, exists := config.Restic.Options[repo]
+	if !exists {
+		opts = &proj.ResticOptions{}
+	}
+
+	if cmd.Flags().Changed("password-file") {
+		opts.PasswordFile = resticPasswordFile
+	}
+	if cmd.Flags().Changed("password-command") {
+		opts.PasswordCommand = resticPasswordCommand
+	}
+
+	for _, kv := range resticEnv {
+		i := strings.IndexByte(kv, '=')
+		if i <= 0 {
+			logrus.WithField("Env", kv).Fatal("environment variables must be given as NAME=VALUE")
+		}
+
+		if opts.Env == nil {
+			opts.Env = make(map[string]string)
+		}
+		opts.Env[kv[:i]] = kv[i+1:]
+	}
+
+	for _, name := range resticUnsetEnv {
+		delete(opts.Env, name)
+	}
+
+	if opts.PasswordFile == "" && opts.PasswordCommand == "" && len(opts.Env) == 0 {
+		delete(config.Restic.Options, repo)
+	} else {
+		config.Restic.Options[repo] = opts
+	}
+}
+
+func addResticOptionFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&resticPasswordFile, "password-file", "", "File containing the repository's password")
+	cmd.PersistentFlags().StringVar(&resticPasswordCommand, "password-command", "", "Command that prints the repository's password")
+	cmd.PersistentFlags().StringArrayVarP(&resticEnv, "env", "e", nil, "Extra environment for restic as NAME=VALUE (e.g. AWS_ACCESS_KEY_ID=...), may be repeated")
+}
+
 var cmdResticAdd = &cobra.Command{
 	Use:   "add REPO",
 	Short: "Add a new restic repo",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		config.Restic.Repositories = append(config.Restic.Repositories, args[0])
+		setResticOptions(cmd, args[0])
+		config.Write()
+	},
+}
+
+var cmdResticSet = &cobra.Command{
+	Use:   "set REPO",
+	Short: "Set the password and environment restic uses for a repo, so it can run unattended",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		found := false
+		for _, repo := range config.Restic.Repositories {
+			found = found || repo == args[0]
+		}
+
+		if !found {
+			logrus.Fatal("Repo not found")
+		}
+
+		setResticOptions(cmd, args[0])
 		config.Write()
 	},
 }
@@ -22,7 +97,20 @@ var cmdResticList = &cobra.Command{
 	Short: "List restic repositories",
 	Run: func(cmd *cobra.Command, args []string) {
 		for _, name := range config.Restic.Repositories {
-			fmt.Println(name)
+			opts, exists := config.Restic.Options[name]
+			if !exists {
+				fmt.Println(name)
+				continue
+			}
+
+			envNames := make([]string, 0, len(opts.Env))
+			for env := range opts.Env {
+				envNames = append(envNames, env)
+			}
+			sort.Strings(envNames)
+
+			fmt.Printf("%s password-file=%q password-command=%q env=%s\n",
+				name, opts.PasswordFile, opts.PasswordCommand, strings.Join(envNames, ","))
 		}
 	},
 }
@@ -46,6 +134,7 @@ var cmdResticRemove = &cobra.Command{
 
 		config.Restic.Repositories =
 			append(config.Restic.Repositories[:idx], config.Restic.Repositories[idx+1:]...)
+		delete(config.Restic.Options, args[0])
 
 		config.Write()
 	},
@@ -57,5 +146,8 @@ var cmdRestic = &cobra.Command{
 }
 
 func init() {
-	cmdRestic.AddCommand(cmdResticAdd, cmdResticRemove, cmdResticList)
+	addResticOptionFlags(cmdResticAdd)
+	addResticOptionFlags(cmdResticSet)
+	cmdResticSet.PersistentFlags().StringArrayVar(&resticUnsetEnv, "unset-env", nil, "Remove an environment variable, may be repeated")
+	cmdRestic.AddCommand(cmdResticAdd, cmdResticSet, cmdResticRemove, cmdResticList)
 }
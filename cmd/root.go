@@ -1,11 +1,12 @@
 package cmd
 
 import (
-	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	proj "github.com/IanS5/go-proj"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -13,19 +14,42 @@ import (
 var config *proj.Config
 var debug = false
 var storageServiceName = ""
-var showRepoList = false
+var templateName = ""
+var visitTmux = false
+
+// openRemote opens a remote's StorageService by name
+func openRemote(service string) (proj.StorageService, error) {
+	name := strings.Trim(service, "\t\r\n\v ")
+	remote, err := config.Remote(name)
+	if err != nil {
+		options := make([]string, 0, len(config.Remotes))
+		for option := range config.Remotes {
+			options = append(options, option)
+		}
+		return nil, errors.Errorf("invalid storage service %q, options are %v", name, options)
+	}
+
+	return remote.Open()
+}
+
+// projectRemote is the remote given with --service, or the project's own remote if --service was omitted
+func projectRemote(repo *proj.ProjectRepository, project string) string {
+	if storageServiceName != "" {
+		return storageServiceName
+	}
+
+	if meta, err := repo.Metadata(project); err == nil {
+		return meta.Remote
+	}
+	return ""
+}
 
 func parseStorageService(service string) proj.StorageService {
-	switch strings.Trim(strings.ToLower(storageServiceName), "\t\r\n\v ") {
-	case "dropbox":
-		return proj.NewDropbox(config.Dropbox.Token)
-	default:
-		logrus.
-			WithField("Service", storageServiceName).
-			WithField("Options", []string{"dropbox"}).
-			Fatal("invalid storage service")
+	s, err := openRemote(service)
+	if err != nil {
+		logrus.WithField("Service", service).Fatal(err)
 	}
-	return nil
+	return s
 }
 
 func makeProjectAction(name string, description string, action func(repo *proj.ProjectRepository, project string) error) (cmd *cobra.Command) {
@@ -46,7 +70,7 @@ func makeProjectAction(name string, description string, action func(repo *proj.P
 				logrus.WithField("Repo", repo).Fatal("repository does not exist")
 			}
 
-			err := action(proj.NewInteractiveLocal(repoPath), args[0])
+			err := action(proj.NewInteractiveLocal(repo, repoPath), args[0])
 			if err != nil {
 				logrus.Fatal(err)
 			}
@@ -57,11 +81,89 @@ func makeProjectAction(name string, description string, action func(repo *proj.P
 	return
 }
 
+// makeAllProjectsAction is like makeProjectAction, but with an --all flag to run the action on every project,
+// in every repository (or only in the repository given with --repo)
+func makeAllProjectsAction(name string, description string, action func(repo *proj.ProjectRepository, project string) error) (cmd *cobra.Command) {
+	list := func(repo *proj.ProjectRepository) ([]string, error) {
+		return repo.List()
+	}
+	return makeListedProjectsAction(name, description, list, action)
+}
+
+// makeListedProjectsAction is like makeAllProjectsAction, but --all runs the action on the projects listed by list
+func makeListedProjectsAction(name string, description string, list func(repo *proj.ProjectRepository) ([]string, error), action func(repo *proj.ProjectRepository, project string) error) (cmd *cobra.Command) {
+	var all bool
+
+	cmd = makeProjectAction(name, description, action)
+	runOne := cmd.Run
+
+	cmd.Use = name + " [PROJECT]"
+	cmd.Args = func(cmd *cobra.Command, args []string) error {
+		if all {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	}
+	cmd.Run = func(cmd *cobra.Command, args []string) {
+		if !all {
+			runOne(cmd, args)
+			return
+		}
+
+		repos := config.ProjectRepositories
+		if cmd.Flags().Changed("repo") {
+			repo, _ := cmd.Flags().GetString("repo")
+			repoPath, exists := config.ProjectRepositories[repo]
+			if !exists {
+				logrus.WithField("Repo", repo).Fatal("repository does not exist")
+			}
+			repos = map[string]string{repo: repoPath}
+		}
+
+		for repo, repoPath := range repos {
+			projectRepo := proj.NewInteractiveLocal(repo, repoPath)
+			projects, err := list(projectRepo)
+			if err != nil {
+				logrus.WithField("Repo", repo).Fatal(err)
+			}
+
+			for _, project := range projects {
+				logrus.WithField("Repo", repo).WithField("Project", project).Infof("Running %s", name)
+				err = action(projectRepo, project)
+				if err != nil {
+					logrus.WithField("Repo", repo).WithField("Project", project).Fatal(err)
+				}
+			}
+		}
+	}
+
+	cmd.Flags().BoolVarP(&all, "all", "a", false, "Run on every project instead of a single PROJECT")
+	return
+}
+
+// applyConfig passes the settings in config on to the proj package
+func applyConfig() {
+	proj.RepositoryNames = make(map[string]bool, len(config.ProjectRepositories))
+	for name := range config.ProjectRepositories {
+		proj.RepositoryNames[name] = true
+	}
+
+	if config.TrashDays != 0 {
+		proj.TrashExpiry = time.Duration(config.TrashDays) * 24 * time.Hour
+	}
+}
+
 var cmdRoot = &cobra.Command{
 	Use:   "proj",
 	Short: "Project manager",
 	Long:  `An application to add convient functionality ontop of your existing filesystem`,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if !cmd.Flags().Changed("tmux") {
+			visitTmux = config.Tmux
+		}
+
+		applyConfig()
+
 		if debug {
 			logrus.SetLevel(logrus.DebugLevel)
 			logrus.Debug("Debugging mode enabled")
@@ -72,15 +174,50 @@ var cmdRoot = &cobra.Command{
 }
 
 var cmdVisit = makeProjectAction("visit",
-	"Open a new instance of your shell in this project's directory",
-	func(repo *proj.ProjectRepository, project string) error {
+	"Go to this project's directory, in a new instance of your shell unless shell integration is loaded",
+	visitProject)
+
+// visitProject changes the current shell to a project's folder when shell integration is loaded, or starts a new shell there
+func visitProject(repo *proj.ProjectRepository, project string) error {
+	if visitTmux {
+		return repo.VisitTmux(project)
+	}
+
+	sh := integratedShell()
+	if sh == "" {
 		return repo.Visit(project)
-	})
+	}
+
+	a, err := repo.Activate(project)
+	if err != nil {
+		return err
+	}
+
+	script, err := sh.Enter(repo.Path(project), a)
+	if err != nil {
+		return err
+	}
+	printShellScript(script)
+	return nil
+}
 
 var cmdCreate = makeProjectAction("create",
-	"Create a new project",
+	"Create a new project, optionally from a template",
 	func(repo *proj.ProjectRepository, project string) error {
-		return repo.Create(project)
+		if templateName == "" {
+			return repo.Create(project)
+		}
+
+		t, exists := config.Templates[templateName]
+		if !exists {
+			return errors.Errorf("template %q does not exist", templateName)
+		}
+
+		author := config.Author
+		if author == "" {
+			author = proj.DefaultAuthor()
+		}
+		return repo.CreateFromTemplate(project, t, author)
 	})
 
 var cmdRemove = makeProjectAction("remove",
@@ -92,38 +229,15 @@ var cmdRemove = makeProjectAction("remove",
 var cmdUpload = makeProjectAction("upload",
 	"Upload a project to a storage service",
 	func(repo *proj.ProjectRepository, project string) error {
-		return repo.Upload(project, parseStorageService(storageServiceName))
+		return repo.Upload(project, parseStorageService(projectRemote(repo, project)))
 	})
 
 var cmdDownload = makeProjectAction("download",
 	"Download a project from a storage service",
 	func(repo *proj.ProjectRepository, project string) error {
-		return repo.Pull(project, parseStorageService(storageServiceName))
+		return repo.Pull(project, parseStorageService(projectRemote(repo, project)))
 	})
 
-var cmdList = &cobra.Command{
-	Use:     "list FILTERS...",
-	Short:   "List projects filtered by a 0 or more regular expressions",
-	Aliases: []string{"l"},
-	Run: func(cmd *cobra.Command, args []string) {
-		for name, path := range config.ProjectRepositories {
-			if projects, err := proj.NewLocal(path).List(args...); err == nil {
-				for _, project := range projects {
-					if showRepoList {
-						fmt.Printf("%s %s\n", name, project)
-					} else {
-						fmt.Println(project)
-
-					}
-				}
-			} else {
-				logrus.WithField("Repo", name).Fatal(err)
-			}
-		}
-		return
-	},
-}
-
 func init() {
 	logrus.SetFormatter(&logrus.TextFormatter{
 		DisableTimestamp:       true,
@@ -131,21 +245,50 @@ func init() {
 		QuoteEmptyFields:       true,
 		DisableSorting:         true,
 	})
-	cmdList.PersistentFlags().BoolVarP(&showRepoList, "show-repo", "w", false, "Show the repo each project comes from")
-	cmdUpload.PersistentFlags().StringVarP(&storageServiceName, "service", "s", "", "The service where the project will be uploaded")
-	cmdDownload.PersistentFlags().StringVarP(&storageServiceName, "service", "s", "", "The service where the project can be downloaded")
+	cmdVisit.PersistentFlags().BoolVarP(&visitTmux, "tmux", "t", false, "Visit the project in its own tmux session, the default can be changed with proj tmux default")
+	cmdCreate.PersistentFlags().StringVarP(&templateName, "template", "t", "", "Template the project is created from")
+	cmdUpload.PersistentFlags().StringVarP(&storageServiceName, "service", "s", "", "The remote where the project will be uploaded")
+	cmdDownload.PersistentFlags().StringVarP(&storageServiceName, "service", "s", "", "The remote where the project can be downloaded")
 
 	cmdRoot.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "Show debugging information")
 	cmdRoot.AddCommand(
 		cmdDropbox,
 		cmdRestic,
+		cmdBorg,
+		cmdNative,
+		cmdBackup,
+		cmdRestore,
+		cmdSnapshots,
+		cmdRemote,
 		cmdUpload,
 		cmdList,
 		cmdDownload,
 		cmdVisit,
+		cmdLeave,
+		cmdJump,
+		cmdRecent,
+		cmdPick,
+		cmdTmux,
+		cmdExec,
+		cmdShellInit,
 		cmdRepo,
 		cmdCreate,
-		cmdRemove)
+		cmdRemove,
+		cmdRename,
+		cmdArchive,
+		cmdUnarchive,
+		cmdTrash,
+		cmdMove,
+		cmdExport,
+		cmdImport,
+		cmdTemplate,
+		cmdMeta,
+		cmdTag,
+		cmdInfo,
+		cmdTrust,
+		cmdUntrust,
+		cmdSchedule,
+		cmdDaemon)
 }
 
 func Execute() {
@@ -0,0 +1,297 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	proj "github.com/IanS5/go-proj"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var jobSchedule = ""
+var jobAction = ""
+var jobProject = ""
+var jobRepo = ""
+var jobRemote = ""
+
+// runJob runs a scheduled job on each of its projects, carrying on past failures so one broken project
+// doesn't stop the others
+func runJob(name string, job *proj.Job) error {
+	var action func(repo *proj.ProjectRepository, project string) error
+
+	switch job.Action {
+	case proj.JobActionBackup:
+		action = backupProject
+	case proj.JobActionUpload:
+		s, err := openRemote(job.Remote)
+		if err != nil {
+			return err
+		}
+
+		action = func(repo *proj.ProjectRepository, project string) error {
+			return repo.Upload(project, s)
+		}
+	default:
+		return errors.Errorf("unknown action %q", job.Action)
+	}
+
+	repos := config.ProjectRepositories
+	if job.Repo != "" || job.Project != "" {
+		repo := job.Repo
+		if repo == "" {
+			repo = config.PrimaryRepo
+		}
+
+		repoPath, exists := config.ProjectRepositories[repo]
+		if !exists {
+			return errors.Errorf("repository %q does not exist", repo)
+		}
+		repos = map[string]string{repo: repoPath}
+	}
+
+	failed := 0
+	for repoName, repoPath := range repos {
+		repo := proj.NewLocal(repoName, repoPath)
+
+		projects := []string{job.Project}
+		if job.Project == "" {
+			var err error
+			projects, err = repo.List()
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, project := range projects {
+			log := logrus.WithField("Job", name).WithField("Repo", repoName).WithField("Project", project)
+			log.Infof("Running %s", job.Action)
+
+			err := action(repo, project)
+			if err != nil {
+				log.WithError(err).Error("Job failed")
+				failed++
+			}
+		}
+	}
+
+	if failed > 0 {
+		return errors.Errorf("%d project(s) failed", failed)
+	}
+	return nil
+}
+
+// runAndRecord runs a job and saves the outcome to the schedule status
+func runAndRecord(name string, job *proj.Job, now time.Time) {
+	err := runJob(name, job)
+
+	status, loadErr := proj.LoadScheduleStatus()
+	if loadErr != nil {
+		logrus.WithError(loadErr).Error("Failed to read the schedule status, starting fresh...")
+		status = make(proj.ScheduleStatus)
+	}
+
+	status.Record(name, now, err)
+	if err := status.Write(); err != nil {
+		logrus.WithError(err).Error("Failed to write the schedule status")
+	}
+}
+
+func sortedJobNames() []string {
+	names := make([]string, 0, len(config.Schedule))
+	for name := range config.Schedule {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var cmdSchedule = &cobra.Command{
+	Use:   "schedule",
+	Short: "Manage recurring backups and uploads, run by proj daemon",
+}
+
+var cmdScheduleAdd = &cobra.Command{
+	Use:   "add NAME",
+	Short: "Add a recurring job, --cron takes a cron expression like \"30 2 * * *\" or @daily",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := proj.ParseSchedule(jobSchedule); err != nil {
+			logrus.WithField("Schedule", jobSchedule).Fatal(err)
+		}
+
+		switch jobAction {
+		case proj.JobActionBackup:
+		case proj.JobActionUpload:
+			if _, err := config.Remote(jobRemote); err != nil {
+				logrus.WithField("Remote", jobRemote).Fatal(err)
+			}
+		default:
+			logrus.
+				WithField("Action", jobAction).
+				WithField("Options", proj.JobActions).
+				Fatal("invalid action")
+		}
+
+		if jobRepo != "" {
+			if _, exists := config.ProjectRepositories[jobRepo]; !exists {
+				logrus.WithField("Repo", jobRepo).Fatal("repository does not exist")
+			}
+		}
+
+		if config.Schedule == nil {
+			config.Schedule = make(map[string]*proj.Job)
+		}
+
+		config.Schedule[args[0]] = &proj.Job{
+			Schedule: jobSchedule,
+			Action:   jobAction,
+			Project:  jobProject,
+			Repo:     jobRepo,
+			Remote:   jobRemote,
+		}
+		config.Write()
+	},
+}
+
+var cmdScheduleList = &cobra.Command{
+	Use:   "list",
+	Short: "List recurring jobs, and when they'll run next",
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, name := range sortedJobNames() {
+			job := config.Schedule[name]
+
+			next := "never"
+			if s, err := proj.ParseSchedule(job.Schedule); err == nil {
+				if t := s.Next(time.Now()); !t.IsZero() {
+					next = t.Format("2006-01-02 15:04")
+				}
+			} else {
+				next = "invalid schedule"
+			}
+
+			project := job.Project
+			if project == "" {
+				project = "(all)"
+			}
+
+			fmt.Printf("%s %q %s %s %s %s next=%s\n", name, job.Schedule, job.Action, job.Repo, project, job.Remote, next)
+		}
+	},
+}
+
+var cmdScheduleRemove = &cobra.Command{
+	Use:   "remove NAME",
+	Short: "Remove a recurring job",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, exists := config.Schedule[args[0]]; !exists {
+			logrus.WithField("Job", args[0]).Fatal("job does not exist")
+		}
+
+		delete(config.Schedule, args[0])
+		config.Write()
+	},
+}
+
+var cmdScheduleRun = &cobra.Command{
+	Use:   "run NAME",
+	Short: "Run a recurring job now",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		job, exists := config.Schedule[args[0]]
+		if !exists {
+			logrus.WithField("Job", args[0]).Fatal("job does not exist")
+		}
+
+		runAndRecord(args[0], job, time.Now())
+	},
+}
+
+var cmdScheduleStatus = &cobra.Command{
+	Use:   "status",
+	Short: "Show when each job last ran, and whether it failed",
+	Run: func(cmd *cobra.Command, args []string) {
+		status, err := proj.LoadScheduleStatus()
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		for _, name := range sortedJobNames() {
+			js, exists := status[name]
+			if !exists {
+				fmt.Printf("%s never-run\n", name)
+				continue
+			}
+
+			state := "ok"
+			if js.LastError != "" {
+				state = "failed"
+			}
+
+			fmt.Printf("%s %s last-run=%s runs=%d failures=%d",
+				name, state, js.LastRun.Format("2006-01-02 15:04"), js.Runs, js.Failures)
+			if js.LastError != "" {
+				fmt.Printf(" error=%q", js.LastError)
+			}
+			fmt.Println()
+		}
+	},
+}
+
+var cmdDaemon = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run scheduled jobs, the schedule is reloaded every minute",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		logrus.Info("Starting scheduler")
+		last := time.Now().Truncate(time.Minute)
+		for {
+			next := last.Add(time.Minute)
+			if wait := time.Until(next); wait > 0 {
+				time.Sleep(wait)
+			}
+
+			now := time.Now().Truncate(time.Minute)
+
+			// a config that's being edited, or was broken, doesn't stop the jobs that were already scheduled
+			if reloaded, err := proj.ReadConfig(); err != nil {
+				logrus.WithError(err).Error("Couldn't reload the config, keeping the previous one")
+			} else {
+				config = reloaded
+				applyConfig()
+			}
+
+			// every minute since the last check is checked, so jobs that were due while other jobs were running
+			// still run, a job that was due more than once only runs once
+			for _, name := range sortedJobNames() {
+				job := config.Schedule[name]
+				s, err := proj.ParseSchedule(job.Schedule)
+				if err != nil {
+					logrus.WithField("Job", name).WithError(err).Error("Skipping job")
+					continue
+				}
+
+				for minute := next; !minute.After(now); minute = minute.Add(time.Minute) {
+					if s.Matches(minute) {
+						runAndRecord(name, job, minute)
+						break
+					}
+				}
+			}
+			last = now
+		}
+	},
+}
+
+func init() {
+	cmdScheduleAdd.PersistentFlags().StringVarP(&jobSchedule, "cron", "c", "@daily", "When the job runs, as a cron expression")
+	cmdScheduleAdd.PersistentFlags().StringVarP(&jobAction, "action", "a", proj.JobActionBackup, "What the job does, backup or upload")
+	cmdScheduleAdd.PersistentFlags().StringVarP(&jobProject, "project", "p", "", "The project the job runs on, or every project if this flag is omitted")
+	cmdScheduleAdd.PersistentFlags().StringVarP(&jobRepo, "repo", "r", "", "Only run on projects in this repo")
+	cmdScheduleAdd.PersistentFlags().StringVarP(&jobRemote, "remote", "s", "", "The remote upload jobs upload to")
+
+	cmdSchedule.AddCommand(cmdScheduleAdd, cmdScheduleList, cmdScheduleRemove, cmdScheduleRun, cmdScheduleStatus)
+}
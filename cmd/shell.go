@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	proj "github.com/IanS5/go-proj"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// integratedShell is the shell proj is being run by the shell integration for, or empty if it isn't
+func integratedShell() proj.Shell {
+	name := os.Getenv(proj.ShellEnvVar)
+	if name == "" {
+		return ""
+	}
+
+	sh, err := proj.ParseShell(name)
+	if err != nil {
+		logrus.WithField("Shell", name).Fatal(err)
+	}
+	return sh
+}
+
+// printShellScript prints a script for the shell integration to run
+func printShellScript(script string) {
+	fmt.Print(proj.ShellScriptMarker + "\n" + script)
+}
+
+var cmdShellInit = &cobra.Command{
+	Use:   "shell-init [bash|zsh|fish]",
+	Short: "Print shell integration, so visiting a project changes the current shell instead of starting a new one",
+	Long: `Print shell integration, so visiting a project changes the current shell instead of starting a new one,
+the shell defaults to $SHELL. Load it from your shell's rc file:
+
+  bash  (~/.bashrc)                  eval "$(proj shell-init bash)"
+  zsh   (~/.zshrc)                   eval "$(proj shell-init zsh)"
+  fish  (~/.config/fish/config.fish) proj shell-init fish | source`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := os.Getenv("SHELL")
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		sh, err := proj.ParseShell(name)
+		if err != nil {
+			logrus.WithField("Shell", name).Fatal(err)
+		}
+		fmt.Print(sh.Init())
+	},
+}
+
+var cmdLeave = &cobra.Command{
+	Use:   "leave",
+	Short: "Leave the project being visited, going back to the previous folder and environment",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		sh := integratedShell()
+		if sh == "" {
+			logrus.Fatal("proj leave needs shell integration, see proj shell-init --help")
+		}
+
+		script, err := sh.Leave()
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		printShellScript(script)
+	},
+}
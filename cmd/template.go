@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	proj "github.com/IanS5/go-proj"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var templateHooks = []string{}
+
+var cmdTemplate = &cobra.Command{
+	Use:   "template",
+	Short: "Manage the templates new projects can be created from",
+}
+
+var cmdTemplateAdd = &cobra.Command{
+	Use:   "add NAME SOURCE",
+	Short: "Add a template, SOURCE is a folder or a git URL",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		source := args[1]
+		if !proj.IsGitURL(source) {
+			abs, err := filepath.Abs(source)
+			if err != nil {
+				logrus.WithField("Source", source).Fatal(err)
+			}
+
+			if info, err := os.Stat(abs); err != nil || !info.IsDir() {
+				logrus.WithField("Source", source).Fatal("template source must be a folder or a git URL")
+			}
+			source = abs
+		}
+
+		if config.Templates == nil {
+			config.Templates = make(map[string]*proj.Template)
+		}
+
+		config.Templates[args[0]] = &proj.Template{
+			Source: source,
+			Hooks:  templateHooks,
+		}
+		config.Write()
+	},
+}
+
+var cmdTemplateList = &cobra.Command{
+	Use:   "list",
+	Short: "List templates, and their hooks",
+	Run: func(cmd *cobra.Command, args []string) {
+		names := make([]string, 0, len(config.Templates))
+		for name := range config.Templates {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			t := config.Templates[name]
+			fmt.Printf("%s %s\n", name, t.Source)
+			for _, hook := range t.Hooks {
+				fmt.Printf("  hook: %s\n", hook)
+			}
+		}
+	},
+}
+
+var cmdTemplateRemove = &cobra.Command{
+	Use:   "remove NAME",
+	Short: "Remove a template (this doesn't delete its source)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, exists := config.Templates[args[0]]; !exists {
+			logrus.WithField("Template", args[0]).Fatal("template does not exist")
+		}
+
+		delete(config.Templates, args[0])
+		config.Write()
+	},
+}
+
+var cmdTemplateAuthor = &cobra.Command{
+	Use:   "author [NAME]",
+	Short: "Show or set the author substituted into templates, git's user.name is used if it isn't set",
+	Args:  cobra.ArbitraryArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			config.Author = strings.Join(args, " ")
+			config.Write()
+		}
+
+		if config.Author != "" {
+			fmt.Println(config.Author)
+		} else {
+			fmt.Println(proj.DefaultAuthor())
+		}
+	},
+}
+
+func init() {
+	cmdTemplateAdd.PersistentFlags().StringArrayVar(&templateHooks, "hook", nil, "Shell command run inside new projects, may be repeated")
+	cmdTemplate.AddCommand(cmdTemplateAdd, cmdTemplateList, cmdTemplateRemove, cmdTemplateAuthor)
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+
+	proj "github.com/IanS5/go-proj"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var tmuxLayout = ""
+var tmuxPanes = []string{}
+
+var cmdTmux = &cobra.Command{
+	Use:   "tmux",
+	Short: "Manage the tmux sessions projects are visited in",
+}
+
+var cmdTmuxDefault = &cobra.Command{
+	Use:       "default [on|off]",
+	Short:     "Show or set whether projects are visited in tmux sessions when --tmux isn't given",
+	Args:      cobra.MaximumNArgs(1),
+	ValidArgs: []string{"on", "off"},
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			switch args[0] {
+			case "on":
+				config.Tmux = true
+			case "off":
+				config.Tmux = false
+			default:
+				logrus.WithField("Default", args[0]).Fatal("expected on or off")
+			}
+			config.Write()
+		}
+
+		if config.Tmux {
+			fmt.Println("on")
+		} else {
+			fmt.Println("off")
+		}
+	},
+}
+
+var cmdTmuxWindow = &cobra.Command{
+	Use:   "window PROJECT NAME",
+	Short: "Add a window to a project's tmux session, or replace the window with the same name",
+	Long: `Add a window to a project's tmux session, or replace the window with the same name. Each --pane is a command
+typed into a new pane (use "" for a plain shell), and --layout is a tmux layout, e.g.
+
+  proj tmux window my-project dev --layout main-vertical --pane vim --pane "" --pane "make watch"
+
+Windows are created when the session is, so kill the session to see changes.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		repoName, _ := cmd.Flags().GetString("repo")
+		repo := projectRepository(repoName)
+		project, name := args[0], args[1]
+
+		trusted, err := repo.IsTrusted(project)
+		if err != nil {
+			logrus.WithField("Project", project).Fatal(err)
+		}
+
+		err = repo.UpdateMetadata(project, func(meta *proj.Metadata) {
+			window := proj.TmuxWindow{Name: name, Layout: tmuxLayout, Panes: tmuxPanes}
+			for i := range meta.TmuxWindows {
+				if meta.TmuxWindows[i].Name == name {
+					meta.TmuxWindows[i] = window
+					return
+				}
+			}
+			meta.TmuxWindows = append(meta.TmuxWindows, window)
+		})
+
+		// the user made this change, so it doesn't need to be trusted again
+		if err == nil && trusted {
+			err = repo.TrustProject(project)
+		}
+
+		if err != nil {
+			logrus.WithField("Project", project).Fatal(err)
+		}
+	},
+}
+
+var cmdTmuxClear = makeProjectAction("clear",
+	"Remove every window from a project's tmux session, so it's created with a single shell",
+	func(repo *proj.ProjectRepository, project string) error {
+		trusted, err := repo.IsTrusted(project)
+		if err != nil {
+			return err
+		}
+
+		err = repo.UpdateMetadata(project, func(meta *proj.Metadata) {
+			meta.TmuxWindows = nil
+		})
+
+		if err == nil && trusted {
+			err = repo.TrustProject(project)
+		}
+		return err
+	})
+
+var cmdTmuxKill = makeProjectAction("kill",
+	"End a project's tmux session",
+	func(repo *proj.ProjectRepository, project string) error {
+		return repo.KillTmux(project)
+	})
+
+var cmdTmuxSession = makeProjectAction("session",
+	"Print the name of a project's tmux session",
+	func(repo *proj.ProjectRepository, project string) error {
+		fmt.Println(repo.TmuxSession(project))
+		return nil
+	})
+
+func init() {
+	cmdTmuxWindow.PersistentFlags().StringP("repo", "r", "", "Repo where the project is located, or the primary repo if this flag is omitted")
+	cmdTmuxWindow.PersistentFlags().StringVarP(&tmuxLayout, "layout", "l", "", "Tmux layout of the window's panes, e.g. even-horizontal, main-vertical or tiled")
+	cmdTmuxWindow.PersistentFlags().StringArrayVarP(&tmuxPanes, "pane", "p", nil, "Command typed into a pane, may be repeated")
+
+	cmdTmux.AddCommand(cmdTmuxDefault, cmdTmuxWindow, cmdTmuxClear, cmdTmuxKill, cmdTmuxSession)
+}
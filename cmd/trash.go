@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	proj "github.com/IanS5/go-proj"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var trashAs = ""
+var trashOlderThan = ""
+var trashYes = false
+
+var cmdTrash = &cobra.Command{
+	Use:   "trash",
+	Short: "Manage removed and overwritten projects, which are kept in the trash for a while",
+}
+
+var cmdTrashList = &cobra.Command{
+	Use:   "list",
+	Short: "List the projects in the trash, most recently trashed first",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := proj.ExpireTrash(); err != nil {
+			logrus.Warnf("Couldn't empty old projects from the trash: %v", err)
+		}
+
+		trashed, err := proj.ListTrash()
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tREPO\tNAME\tTRASHED\tREASON")
+		for _, t := range trashed {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s ago\t%s\n", t.Id[:8], t.Repo, t.Name, formatAge(time.Since(t.Trashed)), t.Reason)
+		}
+		w.Flush()
+	},
+}
+
+var cmdTrashRestore = &cobra.Command{
+	Use:   "restore NAME|ID",
+	Short: "Restore a project from the trash, the most recently trashed project with a name if a name is given",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		repoName, _ := cmd.Flags().GetString("repo")
+		t, err := proj.FindTrashed(args[0])
+		if err != nil {
+			logrus.WithField("Project", args[0]).Fatal(err)
+		}
+
+		// the project goes back to the repository it came from, unless it's given another
+		if repoName == "" {
+			if _, exists := config.ProjectRepositories[t.Repo]; exists {
+				repoName = t.Repo
+			}
+		}
+
+		name := t.Name
+		if trashAs != "" {
+			name = trashAs
+		}
+
+		repo := projectRepository(repoName)
+		err = t.Restore(repo, name)
+		if err != nil {
+			logrus.WithField("Repo", repo.Name()).WithField("Project", name).Fatal(err)
+		}
+		logrus.Infof("Restored %s to %s", name, repo.Path(name))
+	},
+}
+
+var cmdTrashEmpty = &cobra.Command{
+	Use:   "empty",
+	Short: "Delete the projects in the trash for good",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		before := time.Now()
+		if trashOlderThan == "" && !trashYes && !proj.Confirm("Delete every project in the trash for good?") {
+			return
+		}
+
+		if trashOlderThan != "" {
+			var err error
+			before, err = proj.ParseTime(trashOlderThan)
+			if err != nil {
+				logrus.Fatal(err)
+			}
+		}
+
+		removed, err := proj.EmptyTrash(before)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		logrus.Infof("Removed %d projects from the trash", removed)
+	},
+}
+
+var cmdTrashExpiry = &cobra.Command{
+	Use:   "expiry [DAYS|never]",
+	Short: "Show or set how many days projects are kept in the trash",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			if args[0] == "never" {
+				config.TrashDays = -1
+			} else {
+				days, err := strconv.Atoi(args[0])
+				if err != nil || days < 1 {
+					logrus.WithField("Days", args[0]).Fatal("expected a number of days, or never")
+				}
+				config.TrashDays = days
+			}
+			config.Write()
+		}
+
+		switch {
+		case config.TrashDays < 0:
+			fmt.Println("never")
+		case config.TrashDays == 0:
+			fmt.Println(int(proj.TrashExpiry.Hours() / 24))
+		default:
+			fmt.Println(config.TrashDays)
+		}
+	},
+}
+
+func init() {
+	cmdTrashRestore.Flags().StringP("repo", "r", "", "The repository to restore the project to, instead of the one it came from")
+	cmdTrashRestore.Flags().StringVar(&trashAs, "as", "", "A new name for the restored project")
+	cmdTrashEmpty.Flags().BoolVarP(&trashYes, "yes", "y", false, "Don't ask before emptying the whole trash")
+	cmdTrashEmpty.Flags().StringVar(&trashOlderThan, "older-than", "", "Only delete projects trashed before this, a date (2006-01-02) or an age (e.g. 12h, 7d, 2w)")
+
+	cmdTrash.AddCommand(cmdTrashList, cmdTrashRestore, cmdTrashEmpty, cmdTrashExpiry)
+}
@@ -5,51 +5,171 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"strings"
 
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
 var ConfigPath = path.Join(os.Getenv("HOME"), ".proj", "cache.json")
 
 type Config struct {
-	Dropbox struct {
-		AppKey    string `json:"app-key"`
-		AppSecret string `json:"app-secret"`
-		Token     string `json:"token"`
-	} `json:"dropbox"`
+	// LegacyDropbox is the single dropbox account used by older versions of proj,
+	// it's migrated to the "dropbox" remote when the config is loaded
+	LegacyDropbox *Remote `json:"dropbox,omitempty"`
+
+	Remotes map[string]*Remote `json:"remotes"`
 
 	Restic struct {
 		Repositories []string `json:"repositories"`
+
+		// Options are the password and environment settings for each repository
+		Options map[string]*ResticOptions `json:"options,omitempty"`
+
+		// Retention is used to prune projects without their own retention policy
+		Retention RetentionPolicy `json:"retention"`
+
+		// ProjectRetention are retention policies for individual projects, keyed by project Id
+		ProjectRetention map[string]RetentionPolicy `json:"project-retention,omitempty"`
 	} `json:"restic"`
 
+	Borg struct {
+		Repositories []string `json:"repositories"`
+	} `json:"borg"`
+
+	Native struct {
+		// Repositories are local folders, or "remote:NAME" to store backups on a remote
+		Repositories []string `json:"repositories"`
+	} `json:"native"`
+
+	// Templates are the templates new projects can be created from, keyed by template name
+	Templates map[string]*Template `json:"templates,omitempty"`
+
+	// Tmux makes proj visit open projects in tmux sessions by default
+	Tmux bool `json:"tmux,omitempty"`
+
+	// Author is substituted for {{proj.author}} in templates
+	Author string `json:"author,omitempty"`
+
+	// TrashDays is how many days removed projects are kept in the trash, 0 keeps them for the default 30 days
+	// and a negative number keeps them until the trash is emptied
+	TrashDays int `json:"trash-days,omitempty"`
+
+	// Schedule are the recurring jobs run by proj daemon, keyed by job name
+	Schedule map[string]*Job `json:"schedule,omitempty"`
+
 	ProjectRepositories map[string]string `json:"project-repositories"`
 	PrimaryRepo         string            `json:"primary-repo"`
 }
 
 func LoadConfig() (cfg *Config) {
+	cfg, err := ReadConfig()
+	if os.IsNotExist(errors.Cause(err)) {
+		return newConfig()
+	} else if err != nil {
+		logrus.
+			WithError(err).
+			Info("Failed to read config, starting fresh...")
+		return newConfig()
+	}
+	return
+}
+
+// ReadConfig reads the config, unlike LoadConfig a config that's missing or can't be read is an error instead of
+// a fresh config
+func ReadConfig() (cfg *Config, err error) {
+	data, err := ioutil.ReadFile(ConfigPath)
+	if err != nil {
+		return
+	}
+
 	cfg = &Config{}
-	if data, err := ioutil.ReadFile(ConfigPath); err != nil {
-		cfg.ProjectRepositories = make(map[string]string)
-		cfg.Restic.Repositories = make([]string, 0)
+	err = json.Unmarshal(data, cfg)
+	if err != nil {
+		return nil, errors.WithMessage(err, ConfigPath)
+	}
+
+	cfg.migrateLegacyDropbox()
+	return
+}
+
+func newConfig() (cfg *Config) {
+	cfg = &Config{
+		ProjectRepositories: make(map[string]string),
+		Remotes:             make(map[string]*Remote),
+	}
+	cfg.Restic.Repositories = make([]string, 0)
+	return
+}
+
+func (cfg *Config) migrateLegacyDropbox() {
+	if cfg.Remotes == nil {
+		cfg.Remotes = make(map[string]*Remote)
+	}
+
+	if cfg.LegacyDropbox == nil {
 		return
-	} else {
-		err = json.Unmarshal(data, cfg)
-		if err != nil {
-			logrus.
-				WithError(err).
-				Info("Failed to read config, starting fresh...")
-			cfg.ProjectRepositories = make(map[string]string)
-			cfg.Restic.Repositories = make([]string, 0)
-			cfg.Dropbox.AppKey = ""
-			cfg.Dropbox.AppSecret = ""
-			cfg.Dropbox.Token = ""
+	}
+
+	if _, exists := cfg.Remotes[RemoteTypeDropbox]; !exists {
+		logrus.Debug("Migrating the legacy dropbox account to the \"dropbox\" remote")
+		cfg.LegacyDropbox.Type = RemoteTypeDropbox
+		cfg.Remotes[RemoteTypeDropbox] = cfg.LegacyDropbox
+	}
+	cfg.LegacyDropbox = nil
+}
+
+// Remote finds a remote by name
+func (cfg *Config) Remote(name string) (*Remote, error) {
+	remote, exists := cfg.Remotes[name]
+	if !exists {
+		return nil, ErrNoSuchRemote
+	}
+	return remote, nil
+}
+
+// RetentionPolicy finds the retention policy for a project, falling back to the global policy, each of the
+// project's ids is tried in turn (see ProjectRepository.Ids)
+func (cfg *Config) RetentionPolicy(ids ...string) RetentionPolicy {
+	for _, id := range ids {
+		if policy, exists := cfg.Restic.ProjectRetention[id]; exists {
+			return policy
 		}
-		return
+	}
+	return cfg.Restic.Retention
+}
+
+// ResticService creates the restic BackupService, with the settings for each repository
+func (cfg *Config) ResticService() Restic {
+	return Restic{Options: cfg.Restic.Options}
+}
+
+// NativeService creates the built-in BackupService, repositories named "remote:NAME" are kept in the
+// proj-backups folder of that remote
+func (cfg *Config) NativeService() Native {
+	return Native{
+		Open: func(repository string) (StorageService, error) {
+			if !strings.HasPrefix(repository, NativeRemotePrefix) {
+				return NewLocalStorage(repository), nil
+			}
+
+			remote, err := cfg.Remote(strings.TrimPrefix(repository, NativeRemotePrefix))
+			if err != nil {
+				return nil, err
+			}
+
+			s, err := remote.Open()
+			if err != nil {
+				return nil, err
+			}
+			return Subfolder(s, "/proj-backups"), nil
+		},
 	}
 }
 
 func (cfg *Config) Write() {
-	f, err := os.Create(ConfigPath)
+	// the config holds credentials, so only the owner can read it
+	f, err := os.OpenFile(ConfigPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		logrus.
 			WithError(err).
@@ -61,7 +181,7 @@ func (cfg *Config) Write() {
 			os.MkdirAll(dir, 0755)
 
 			logrus.WithField("Dir", dir).Info("Retry...")
-			f, err = os.Create(ConfigPath)
+			f, err = os.OpenFile(ConfigPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 			if err == nil {
 				logrus.Info("Success")
 			} else {
@@ -74,6 +194,9 @@ func (cfg *Config) Write() {
 		}
 	}
 
+	defer f.Close()
+	f.Chmod(0600)
+
 	err = json.NewEncoder(f).Encode(cfg)
 	if err != nil {
 		logrus.
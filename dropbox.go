@@ -22,7 +22,13 @@ type Dropbox struct {
 	dropboxFolder string
 }
 
+// abs converts a path relative to the dropbox folder into an absolute dropbox path
+func (db *Dropbox) abs(remote string) string {
+	return path.Join("/", db.dropboxFolder, remote)
+}
+
 func (db *Dropbox) WalkDiffs(local, remote string, skip SkipCallback, cb WalkDiffsCallback) error {
+	remote = db.abs(remote)
 	folders, err := db.client.ListFolder(&files.ListFolderArg{
 		Path:             remote,
 		IncludeMediaInfo: false,
@@ -120,8 +126,54 @@ func (db *Dropbox) WalkDiffs(local, remote string, skip SkipCallback, cb WalkDif
 	return err
 }
 
+func (db *Dropbox) List(remote string) (names []string, err error) {
+	remote = db.abs(remote)
+	res, err := db.client.ListFolder(&files.ListFolderArg{
+		Path:             remote,
+		IncludeMediaInfo: false,
+		Recursive:        true,
+		IncludeDeleted:   false})
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "path/not_found/") {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	for {
+		for _, ent := range res.Entries {
+			if v, ok := ent.(*files.FileMetadata); ok {
+				strippedFile, err := filepath.Rel(remote, v.PathDisplay)
+				if err != nil {
+					return nil, errors.WithMessage(err, "failed to make remote filepath relative")
+				}
+				names = append(names, filepath.ToSlash(strippedFile))
+			}
+		}
+
+		if !res.HasMore {
+			return
+		}
+
+		res, err = db.client.ListFolderContinue(files.NewListFolderContinueArg(res.Cursor))
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
 func (db *Dropbox) Delete(f string) (err error) {
-	_, err = db.client.DeleteV2(files.NewDeleteArg(f))
+	_, err = db.client.DeleteV2(files.NewDeleteArg(db.abs(f)))
+	return
+}
+
+func (db *Dropbox) Move(from, to string) (err error) {
+	arg := files.NewRelocationArg(db.abs(from), db.abs(to))
+	arg.Autorename = false
+	_, err = db.client.MoveV2(arg)
+	if err != nil && strings.HasPrefix(err.Error(), "from_lookup/not_found/") {
+		return nil
+	}
 	return
 }
 
@@ -141,7 +193,7 @@ func (db *Dropbox) Upload(local, remote string) (err error) {
 	}
 	size := info.Size()
 
-	commitInfo := files.NewCommitInfo(remote)
+	commitInfo := files.NewCommitInfo(db.abs(remote))
 	commitInfo.Mode.Tag = "overwrite"
 	commitInfo.ClientModified = time.Now().UTC().Round(time.Second)
 
@@ -208,7 +260,7 @@ func ContentHash(r io.Reader) (string, error) {
 
 func (db *Dropbox) HashRemote(name string) (hash string, err error) {
 	metadata, err := db.client.GetMetadata(
-		files.NewGetMetadataArg(name))
+		files.NewGetMetadataArg(db.abs(name)))
 
 	if err != nil {
 		return
@@ -229,7 +281,7 @@ func (db *Dropbox) HashLocal(file string) (hash string, err error) {
 }
 
 func (db *Dropbox) Download(local, remote string) (err error) {
-	_, result, err := db.client.Download(files.NewDownloadArg(remote))
+	_, result, err := db.client.Download(files.NewDownloadArg(db.abs(remote)))
 
 	if err != nil {
 		return
@@ -249,8 +301,10 @@ func (db *Dropbox) Download(local, remote string) (err error) {
 	return
 }
 
-func NewDropbox(token string) *Dropbox {
+// NewDropbox creates a StorageService for a dropbox account, all paths are relative to folder
+func NewDropbox(token, folder string) *Dropbox {
 	return &Dropbox{
-		client: files.New(dropbox.Config{Token: token}),
+		client:        files.New(dropbox.Config{Token: token}),
+		dropboxFolder: folder,
 	}
 }
@@ -0,0 +1,56 @@
+package proj
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// lookPathIn finds an executable in the folders of a PATH variable, like exec.LookPath does with proj's own PATH
+func lookPathIn(file, pathVar string) (string, error) {
+	if strings.ContainsRune(file, '/') {
+		return file, nil
+	}
+
+	for _, dir := range filepath.SplitList(pathVar) {
+		if dir == "" {
+			dir = "."
+		}
+
+		candidate := filepath.Join(dir, file)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() && info.Mode()&0111 != 0 {
+			return candidate, nil
+		}
+	}
+	return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+}
+
+// Exec runs a command in a project's folder, with the environment visiting the project sets, it doesn't count as
+// a visit, and the project's hooks aren't run
+func (fr *ProjectRepository) Exec(name string, args []string, stdout, stderr io.Writer) (err error) {
+	a, err := fr.Activation(name)
+	if err != nil {
+		return
+	}
+
+	env := modEnviron(a.Env)
+	pathVar, exists := a.Env["PATH"]
+	if !exists {
+		pathVar = os.Getenv("PATH")
+	}
+
+	// the command is looked up on the project's PATH, so its own tools are used
+	command, err := lookPathIn(args[0], pathVar)
+	if err != nil {
+		return
+	}
+
+	cmd := exec.Command(command, args[1:]...)
+	cmd.Dir = fr.Path(name)
+	cmd.Env = env
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
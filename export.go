@@ -0,0 +1,459 @@
+package proj
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrUnknownArchiveFormat = errors.New("Unknown archive format, expected .tar, .tar.gz or .tar.zst")
+	ErrInvalidArchive       = errors.New("Not a proj export archive")
+)
+
+const (
+	exportHeaderFile   = "proj.json"
+	exportHistoryFile  = "history"
+	exportMetadataFile = "metadata"
+	exportFilesDir     = "files/"
+)
+
+// ExportHeader describes the project inside an export archive
+type ExportHeader struct {
+	Name     string    `json:"name"`
+	Id       string    `json:"id"`
+	Repo     string    `json:"repo"`
+	Exported time.Time `json:"exported"`
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+type zstdReadCloser struct {
+	*zstd.Decoder
+}
+
+func (z zstdReadCloser) Close() error {
+	z.Decoder.Close()
+	return nil
+}
+
+// archiveFormat picks an archive's compression by its extension
+func archiveFormat(file string) (string, error) {
+	switch {
+	case strings.HasSuffix(file, ".tar.zst"), strings.HasSuffix(file, ".tzst"):
+		return "zst", nil
+	case strings.HasSuffix(file, ".tar.gz"), strings.HasSuffix(file, ".tgz"):
+		return "gz", nil
+	case strings.HasSuffix(file, ".tar"):
+		return "tar", nil
+	default:
+		return "", ErrUnknownArchiveFormat
+	}
+}
+
+func compressor(format string, w io.Writer) (io.WriteCloser, error) {
+	switch format {
+	case "zst":
+		return zstd.NewWriter(w)
+	case "gz":
+		return gzip.NewWriter(w), nil
+	default:
+		return nopWriteCloser{w}, nil
+	}
+}
+
+func decompressor(format string, r io.Reader) (io.ReadCloser, error) {
+	switch format {
+	case "zst":
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zstdReadCloser{d}, nil
+	case "gz":
+		return gzip.NewReader(r)
+	default:
+		return ioutil.NopCloser(r), nil
+	}
+}
+
+func writeTarFile(tw *tar.Writer, name, file string, info os.FileInfo) (err error) {
+	link := ""
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err = os.Readlink(file)
+		if err != nil {
+			return
+		}
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return
+	}
+	header.Name = name
+
+	err = tw.WriteHeader(header)
+	if err != nil || !info.Mode().IsRegular() {
+		return
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	_, err = io.Copy(tw, f)
+	return
+}
+
+// Export packs a project, its metadata, its shell history and a header describing it into an archive. Files matched
+// by the project's .gitignore are left out, but the metadata never is. The archive's compression is picked by its
+// extension.
+func (fr *ProjectRepository) Export(name, archive string) (err error) {
+	folder := fr.Path(name)
+	if _, err := os.Stat(folder); os.IsNotExist(err) {
+		return ErrNoSuchProject
+	} else if err != nil {
+		return err
+	}
+
+	format, err := archiveFormat(archive)
+	if err != nil {
+		return
+	}
+
+	ignore, err := loadIgnore(folder)
+	if err != nil {
+		return
+	}
+
+	f, err := os.Create(archive)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	cw, err := compressor(format, f)
+	if err != nil {
+		return
+	}
+	tw := tar.NewWriter(cw)
+
+	header, err := json.Marshal(ExportHeader{
+		Name:     name,
+		Id:       fr.Id(name),
+		Repo:     fr.name,
+		Exported: time.Now(),
+	})
+	if err != nil {
+		return
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:    exportHeaderFile,
+		Mode:    0644,
+		Size:    int64(len(header)),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return
+	}
+
+	if _, err = tw.Write(header); err != nil {
+		return
+	}
+
+	histFile := fr.HistFile(name)
+	if info, err := os.Stat(histFile); err == nil {
+		err = writeTarFile(tw, exportHistoryFile, histFile, info)
+		if err != nil {
+			return err
+		}
+	}
+
+	metaFile := path.Join(folder, MetadataFile)
+	if info, err := os.Lstat(metaFile); err == nil && info.Mode().IsRegular() {
+		err = writeTarFile(tw, exportMetadataFile, metaFile, info)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = filepath.Walk(folder, func(file string, info os.FileInfo, walkErr error) (err error) {
+		if walkErr != nil {
+			return walkErr
+		}
+
+		strippedFile, err := filepath.Rel(folder, file)
+		if err != nil {
+			return err
+		}
+
+		// the metadata has its own entry
+		if strippedFile == "." || strippedFile == MetadataFile {
+			return nil
+		}
+
+		if ignore != nil && ignore.MatchesPath(strippedFile) {
+			logrus.Debugf("Skipping %q", strippedFile)
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		return writeTarFile(tw, exportFilesDir+filepath.ToSlash(strippedFile), file, info)
+	})
+	if err != nil {
+		return
+	}
+
+	if err = tw.Close(); err != nil {
+		return
+	}
+	return cw.Close()
+}
+
+// ReadExportHeader reads the header of an export archive, without unpacking it
+func ReadExportHeader(archive string) (header ExportHeader, err error) {
+	format, err := archiveFormat(archive)
+	if err != nil {
+		return
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	dr, err := decompressor(format, f)
+	if err != nil {
+		return
+	}
+	defer dr.Close()
+
+	tr := tar.NewReader(dr)
+	h, err := tr.Next()
+	if err != nil || h.Name != exportHeaderFile {
+		return header, ErrInvalidArchive
+	}
+
+	err = json.NewDecoder(tr).Decode(&header)
+	return
+}
+
+// Import unpacks an export archive into this repository. The project is named as, or keeps its original name
+// if as is empty, the imported project's name is returned.
+func (fr *ProjectRepository) Import(archive, as string) (name string, err error) {
+	header, err := ReadExportHeader(archive)
+	if err != nil {
+		return
+	}
+
+	name = header.Name
+	if as != "" {
+		name = as
+	}
+
+	if err = ValidateName(name); err != nil {
+		return
+	}
+
+	folder := fr.Path(name)
+	if _, err := os.Stat(folder); !os.IsNotExist(err) {
+		if !fr.interactive || !Confirm("%s already exists, overwrite it?", name) {
+			return name, ErrProjectExists
+		}
+	}
+
+	err = os.MkdirAll(fr.baseFolder, projectFolderPerm)
+	if err != nil {
+		return
+	}
+
+	// unpack next to the destination, so moving it into place doesn't need to cross filesystems
+	tmpdir, err := ioutil.TempDir(fr.baseFolder, ".proj-import")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(tmpdir)
+
+	format, err := archiveFormat(archive)
+	if err != nil {
+		return
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	dr, err := decompressor(format, f)
+	if err != nil {
+		return
+	}
+	defer dr.Close()
+
+	unpacked := path.Join(tmpdir, "files")
+	err = os.MkdirAll(unpacked, projectFolderPerm)
+	if err != nil {
+		return
+	}
+
+	tr := tar.NewReader(dr)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return name, err
+		}
+
+		switch {
+		case h.Name == exportHeaderFile:
+			// already read
+		case h.Name == exportHistoryFile:
+			err = extractTarFile(tr, h, tmpdir, path.Join(tmpdir, exportHistoryFile))
+		case h.Name == exportMetadataFile && h.Typeflag == tar.TypeReg:
+			err = extractTarFile(tr, h, tmpdir, path.Join(tmpdir, exportMetadataFile))
+		case strings.HasPrefix(h.Name, exportFilesDir):
+			rel := path.Clean(strings.TrimPrefix(h.Name, exportFilesDir))
+			if rel == ".." || strings.HasPrefix(rel, "../") || path.IsAbs(rel) {
+				return name, errors.WithMessage(ErrInvalidArchive, "file outside of the project "+h.Name)
+			}
+			err = extractTarFile(tr, h, unpacked, filepath.Join(unpacked, filepath.FromSlash(rel)))
+		default:
+			logrus.Debugf("Skipping unknown archive entry %q", h.Name)
+		}
+
+		if err != nil {
+			return name, err
+		}
+	}
+
+	// archives made before the metadata had its own entry keep it with the project's files
+	if _, err := os.Lstat(path.Join(tmpdir, exportMetadataFile)); err == nil {
+		err = os.Rename(path.Join(tmpdir, exportMetadataFile), path.Join(unpacked, MetadataFile))
+		if err != nil {
+			return name, errors.WithMessage(err, "while importing metadata")
+		}
+	}
+
+	if _, err := os.Stat(folder); err == nil {
+		_, err = fr.trash(name, TrashReasonOverwritten)
+		if err != nil {
+			return name, errors.WithMessage(err, "while moving the old project to the trash")
+		}
+	}
+
+	err = os.Rename(unpacked, folder)
+	if err != nil {
+		return
+	}
+
+	// a project imported under another name is a copy, so it can't share the original's Id
+	if as != "" && as != header.Name {
+		err = fr.renewId(name)
+		if err != nil {
+			return
+		}
+	}
+
+	if _, err := os.Stat(path.Join(tmpdir, exportHistoryFile)); err == nil {
+		histFile := fr.HistFile(name)
+		err = os.MkdirAll(path.Dir(histFile), 0700)
+		if err == nil {
+			err = os.Rename(path.Join(tmpdir, exportHistoryFile), histFile)
+		}
+		if err != nil {
+			err = copyFile(path.Join(tmpdir, exportHistoryFile), histFile)
+		}
+		if err != nil {
+			return name, errors.WithMessage(err, "while importing shell history")
+		}
+	}
+	return
+}
+
+// throughSymlink is true if writing to dest, inside root, would follow a symlink, which an archive could have
+// planted to write outside of root
+func throughSymlink(root, dest string) (bool, error) {
+	rel, err := filepath.Rel(root, dest)
+	if err != nil {
+		return false, err
+	}
+
+	current := root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// extractTarFile writes an archive entry to dest, inside root
+func extractTarFile(tr *tar.Reader, h *tar.Header, root, dest string) (err error) {
+	linked, err := throughSymlink(root, dest)
+	if err != nil {
+		return
+	} else if linked {
+		return errors.WithMessage(ErrInvalidArchive, "file written through a symlink "+h.Name)
+	}
+
+	err = os.MkdirAll(path.Dir(dest), projectFolderPerm)
+	if err != nil {
+		return
+	}
+
+	mode := os.FileMode(h.Mode).Perm()
+	switch h.Typeflag {
+	case tar.TypeDir:
+		return os.MkdirAll(dest, mode|0700)
+	case tar.TypeSymlink:
+		return os.Symlink(h.Linkname, dest)
+	case tar.TypeReg:
+		f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(f, tr)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(dest, h.ModTime, h.ModTime)
+	default:
+		logrus.Debugf("Skipping special file %q", h.Name)
+		return nil
+	}
+}
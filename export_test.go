@@ -0,0 +1,119 @@
+package proj
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// writeTestArchive writes an export archive of a project named foo, with entries in the order given
+func writeTestArchive(t *testing.T, archive string, entries ...*tar.Header) {
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	header, err := json.Marshal(ExportHeader{Name: "foo", Id: "foo-id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tw := tar.NewWriter(f)
+	entries = append([]*tar.Header{{Name: exportHeaderFile, Mode: 0644, Size: int64(len(header))}}, entries...)
+	for i, h := range entries {
+		if h.Typeflag == 0 {
+			h.Typeflag = tar.TypeReg
+		}
+
+		err = tw.WriteHeader(h)
+		if err == nil && i == 0 {
+			_, err = tw.Write(header)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportKeepsIgnoredMetadata(t *testing.T) {
+	fr, cleanupRepo := testRepository(t)
+	defer cleanupRepo()
+
+	err := fr.UpdateMetadata("foo", func(meta *Metadata) {
+		meta.Description = "exported"
+	})
+	if err == nil {
+		err = ioutil.WriteFile(path.Join(fr.Path("foo"), ".gitignore"), []byte("*.json\n"), 0644)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive := path.Join(path.Dir(fr.Path("")), "foo.tar")
+	err = fr.Export("foo", archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := NewLocal("other", path.Join(path.Dir(fr.Path("")), "other"))
+	name, err := other.Import(archive, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta, err := other.Metadata(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if meta.Id != "foo-id" || meta.Description != "exported" {
+		t.Errorf("expected the metadata to be imported, got %+v", meta)
+	}
+}
+
+func TestImportRejectsFilesOutsideTheProject(t *testing.T) {
+	fr, cleanupRepo := testRepository(t)
+	defer cleanupRepo()
+
+	base := path.Dir(fr.Path(""))
+	outside := path.Join(base, "outside")
+	err := os.Mkdir(outside, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, entries := range map[string][]*tar.Header{
+		"parent":   {{Name: exportFilesDir + "../../../outside/evil", Mode: 0644}},
+		"absolute": {{Name: exportFilesDir + "/../../outside/evil", Mode: 0644}},
+		"symlink": {
+			{Name: exportFilesDir + "link", Typeflag: tar.TypeSymlink, Linkname: outside, Mode: 0777},
+			{Name: exportFilesDir + "link/evil", Mode: 0644},
+		},
+	} {
+		archive := path.Join(base, name+".tar")
+		writeTestArchive(t, archive, entries...)
+
+		_, err = fr.Import(archive, "imported")
+		if errors.Cause(err) != ErrInvalidArchive {
+			t.Errorf("%s: expected %v, got %v", name, ErrInvalidArchive, err)
+		}
+
+		if _, err := os.Stat(path.Join(outside, "evil")); !os.IsNotExist(err) {
+			t.Errorf("%s: expected nothing to be written outside of the project, got %v", name, err)
+		}
+
+		if _, err := os.Stat(fr.Path("imported")); !os.IsNotExist(err) {
+			t.Errorf("%s: expected the project not to be imported, got %v", name, err)
+		}
+	}
+}
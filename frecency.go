@@ -0,0 +1,225 @@
+package proj
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+var FrecencyPath = path.Join(os.Getenv("HOME"), ".proj", "frecency.json")
+
+// frecencyMaxVisits is the total number of visits kept, once there are more every project's visits are aged so
+// projects that aren't used anymore drop off
+const frecencyMaxVisits = 10000
+
+// Visits records how often, and how recently, a project was visited
+type Visits struct {
+	Repo  string    `json:"repo"`
+	Name  string    `json:"name"`
+	Count float64   `json:"count"`
+	Last  time.Time `json:"last"`
+}
+
+// Score ranks a project by frecency, visits count for more the more recent the last visit was
+func (v *Visits) Score(now time.Time) float64 {
+	age := now.Sub(v.Last)
+	switch {
+	case age < time.Hour:
+		return v.Count * 4
+	case age < 24*time.Hour:
+		return v.Count * 2
+	case age < 7*24*time.Hour:
+		return v.Count / 2
+	}
+	return v.Count / 4
+}
+
+// Frecency is the visit history of every project, keyed by repository and project name
+type Frecency map[string]*Visits
+
+func frecencyKey(repo, name string) string {
+	return repo + "/" + name
+}
+
+// LoadFrecency reads the visit history, a missing history is empty
+func LoadFrecency() (f Frecency, err error) {
+	f = make(Frecency)
+	data, err := ioutil.ReadFile(FrecencyPath)
+	if os.IsNotExist(err) {
+		return f, nil
+	} else if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(data, &f)
+	return
+}
+
+func (f Frecency) Write() error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(path.Dir(FrecencyPath), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(FrecencyPath, data, 0600)
+}
+
+// Visit records a visit to a project
+func (f Frecency) Visit(repo, name string, t time.Time) {
+	key := frecencyKey(repo, name)
+	v, exists := f[key]
+	if !exists {
+		v = &Visits{Repo: repo, Name: name}
+		f[key] = v
+	}
+	v.Count++
+	v.Last = t
+
+	total := 0.0
+	for _, v := range f {
+		total += v.Count
+	}
+
+	if total > frecencyMaxVisits {
+		for key, v := range f {
+			v.Count *= 0.9
+			if v.Count < 1 {
+				delete(f, key)
+			}
+		}
+	}
+}
+
+// Forget removes a project from the visit history
+func (f Frecency) Forget(repo, name string) {
+	delete(f, frecencyKey(repo, name))
+}
+
+// Rename moves a project's visits to its new repository and name
+func (f Frecency) Rename(repo, name, newRepo, newName string) {
+	v, exists := f[frecencyKey(repo, name)]
+	if !exists {
+		return
+	}
+
+	delete(f, frecencyKey(repo, name))
+	v.Repo, v.Name = newRepo, newName
+	f[frecencyKey(newRepo, newName)] = v
+}
+
+// Get finds a project's visits, projects that were never visited have no visits
+func (f Frecency) Get(repo, name string) *Visits {
+	if v, exists := f[frecencyKey(repo, name)]; exists {
+		return v
+	}
+	return &Visits{Repo: repo, Name: name}
+}
+
+// Ranked lists every visited project, highest frecency first
+func (f Frecency) Ranked(now time.Time) []*Visits {
+	ranked := make([]*Visits, 0, len(f))
+	for _, v := range f {
+		ranked = append(ranked, v)
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		si, sj := ranked[i].Score(now), ranked[j].Score(now)
+		if si != sj {
+			return si > sj
+		}
+		return ranked[i].Last.After(ranked[j].Last)
+	})
+	return ranked
+}
+
+// matchQuality ranks how well a project's name matches a query, 0 means it doesn't match, then a fuzzy match,
+// a substring, a prefix and an exact match are each better than the last
+func matchQuality(query, name string) int {
+	query, name = strings.ToLower(query), strings.ToLower(name)
+	switch {
+	case query == name:
+		return 4
+	case strings.HasPrefix(name, query):
+		return 3
+	case strings.Contains(name, query):
+		return 2
+	case fuzzyMatch(query, name):
+		return 1
+	}
+	return 0
+}
+
+// JumpCandidate is a project matching a jump query
+type JumpCandidate struct {
+	Repo    *ProjectRepository
+	Name    string
+	Score   float64
+	exact   bool
+	quality int
+}
+
+// Jump ranks the projects of every repository against a query, every word of which has to fuzzily match the
+// project's name (words with a / are matched against "repo/name" instead), projects whose name is exactly the
+// query come first, then projects are ranked by frecency, then by how well they match
+func (f Frecency) Jump(repos []*ProjectRepository, query []string) (candidates []*JumpCandidate, err error) {
+	now := time.Now()
+	for _, repo := range repos {
+		projects, err := repo.List()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, project := range projects {
+			quality := 0
+			for _, word := range query {
+				name := project
+				if strings.ContainsRune(word, '/') {
+					name = repo.Name() + "/" + project
+				}
+
+				q := matchQuality(word, name)
+				if q == 0 {
+					quality = 0
+					break
+				}
+				quality += q
+			}
+
+			if quality == 0 && len(query) > 0 {
+				continue
+			}
+
+			candidates = append(candidates, &JumpCandidate{
+				Repo:    repo,
+				Name:    project,
+				Score:   f.Get(repo.Name(), project).Score(now),
+				exact:   quality == 4*len(query),
+				quality: quality,
+			})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		switch {
+		case a.exact != b.exact:
+			return a.exact
+		case a.Score != b.Score:
+			return a.Score > b.Score
+		case a.quality != b.quality:
+			return a.quality > b.quality
+		case a.Name != b.Name:
+			return a.Name < b.Name
+		}
+		return a.Repo.Name() < b.Repo.Name()
+	})
+	return
+}
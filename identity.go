@@ -0,0 +1,364 @@
+package proj
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// remoteProjectsFolder is the folder on a StorageService projects are uploaded to, each in a folder named
+	// after its Id
+	remoteProjectsFolder = "proj-projects"
+
+	// remoteNamesFolder is the folder on a StorageService that maps each uploaded project's repository and name
+	// to its Id, so it can be downloaded by name
+	remoteNamesFolder = "proj-names"
+)
+
+// newId makes a random (version 4) UUID
+func newId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// legacyId is the Id projects had before they were given a persistent one
+func legacyId(name string) string {
+	// "Project##{name-of-project}" -> Sha256 -> Hex
+	hashed := sha256.Sum256([]byte("Project##" + name))
+	return hex.EncodeToString(hashed[:])
+}
+
+// Id is the project's persistent Id, kept in its metadata. Projects that haven't been given one yet (see
+// ensureId), and projects that don't exist, use their old, name based, Id.
+func (fr *ProjectRepository) Id(name string) string {
+	if meta, err := fr.Metadata(name); err == nil && meta.Id != "" {
+		return meta.Id
+	}
+	return legacyId(name)
+}
+
+// IdFoldersPath is where the folder each Id was last seen in on this machine is kept
+var IdFoldersPath = path.Join(os.Getenv("HOME"), ".proj", "ids.json")
+
+// IdFolders is the folder each Id was last seen in, it's kept out of the projects' metadata since it's only
+// true on this machine
+type IdFolders map[string]string
+
+// LoadIdFolders reads the folder of every Id, a missing file means none have been seen yet
+func LoadIdFolders() (folders IdFolders, err error) {
+	folders = make(IdFolders)
+	data, err := ioutil.ReadFile(IdFoldersPath)
+	if os.IsNotExist(err) {
+		return folders, nil
+	} else if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(data, &folders)
+	return
+}
+
+func (folders IdFolders) Write() error {
+	data, err := json.MarshalIndent(folders, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(path.Dir(IdFoldersPath), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(IdFoldersPath, data, 0600)
+}
+
+// copied is true if folder is a copy of the project the Id was last seen in, which still has the same Id
+func (folders IdFolders) copied(id, folder string) bool {
+	seen := folders[id]
+	if seen == "" || seen == folder {
+		return false
+	}
+
+	original, err := NewLocal("", path.Dir(seen)).Metadata(path.Base(seen))
+	return err == nil && original.Id == id
+}
+
+// ensureId gives a project a persistent Id if it doesn't have one yet, moving everything kept under its name
+// based Id over, and gives a project that's a copy of another one a fresh Id. It writes the project's metadata,
+// so it's only used when a project is created, uploaded, backed up or moved, never when it's only looked at.
+func (fr *ProjectRepository) ensureId(name string) (id string, err error) {
+	meta, err := fr.Metadata(name)
+	if err != nil {
+		return
+	}
+
+	folders, err := LoadIdFolders()
+	if err != nil {
+		return
+	}
+
+	folder := fr.Path(name)
+	switch {
+	case meta.Id == "":
+		id, err = fr.migrateId(name, meta, newId())
+	case folders.copied(meta.Id, folder):
+		logrus.Infof("%s is a copy of %s, giving it a new id", name, folders[meta.Id])
+		meta.Id, meta.LegacyId = newId(), ""
+		id, err = meta.Id, fr.WriteMetadata(name, meta)
+	default:
+		id = meta.Id
+	}
+	if err != nil || folders[id] == folder {
+		return
+	}
+
+	folders[id] = folder
+	return id, folders.Write()
+}
+
+// renewId gives a project a fresh Id, for a project that's a copy of another one
+func (fr *ProjectRepository) renewId(name string) error {
+	meta, err := fr.Metadata(name)
+	if err != nil {
+		return err
+	}
+
+	meta.Id, meta.LegacyId = newId(), ""
+	err = fr.WriteMetadata(name, meta)
+	if err != nil {
+		return err
+	}
+
+	_, err = fr.ensureId(name)
+	return err
+}
+
+// Ids are every Id the project has had, its persistent Id first
+func (fr *ProjectRepository) Ids(name string) []string {
+	ids := []string{fr.Id(name)}
+	if meta, err := fr.Metadata(name); err == nil && meta.LegacyId != "" && meta.LegacyId != ids[0] {
+		ids = append(ids, meta.LegacyId)
+	}
+	return ids
+}
+
+// fishHistoryName is the name of a project's fish history, fish only allows letters, numbers and underscores
+func fishHistoryName(id string) string {
+	return strings.Replace(id, "-", "_", -1)
+}
+
+func fishHistoryFile(historyName string) string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = path.Join(os.Getenv("HOME"), ".local", "share")
+	}
+	return path.Join(dataHome, "fish", historyName+"_history")
+}
+
+// migrateId gives a project a persistent Id, then moves everything that was kept under its name based Id
+func (fr *ProjectRepository) migrateId(name string, meta *Metadata, id string) (string, error) {
+	old := legacyId(name)
+	meta.Id, meta.LegacyId = id, old
+	err := fr.WriteMetadata(name, meta)
+	if err != nil {
+		return "", err
+	}
+	logrus.Debugf("Gave %s the persistent id %s", name, id)
+
+	histories := [][2]string{
+		{path.Join(os.Getenv("HOME"), ".proj", "hist", old), fr.HistFile(name)},
+		{fishHistoryFile(old), fishHistoryFile(fishHistoryName(id))},
+	}
+	for _, history := range histories {
+		if _, err := os.Stat(history[1]); err == nil {
+			continue
+		}
+
+		if err := os.Rename(history[0], history[1]); err != nil && !os.IsNotExist(err) {
+			logrus.Warnf("Couldn't move the shell history of %s: %v", name, err)
+		}
+	}
+
+	err = renameTmux(tmuxSessionName(name, old), tmuxSessionName(name, id))
+	if err != nil {
+		logrus.Warnf("Couldn't rename the tmux session of %s: %v", name, err)
+	}
+	return id, nil
+}
+
+// RemotePath is where a project that exists locally is uploaded to on a StorageService
+func (fr *ProjectRepository) RemotePath(name string) string {
+	return path.Join("/", remoteProjectsFolder, fr.Id(name))
+}
+
+// RepositoryNames are the names of every configured repository. Projects used to be uploaded to a folder named
+// after them, which can't be told apart from the folder a repository's projects were later uploaded to, so
+// that folder is only used for projects that aren't named after a repository, and not at all if this is nil.
+var RepositoryNames map[string]bool
+
+// legacyRemotePath is where projects were uploaded to before they had persistent Ids, namespaced by their
+// repository's name
+func (fr *ProjectRepository) legacyRemotePath(name string) string {
+	return path.Join("/", fr.name, name)
+}
+
+// legacyRemoteFolder finds a project uploaded before it had a persistent Id, in its name based folder, or in
+// the folder it was uploaded to before projects were namespaced by their repository's name. found is false if
+// it isn't in either.
+func (fr *ProjectRepository) legacyRemoteFolder(name string, s StorageService) (folder string, found bool, err error) {
+	files, err := s.List(fr.legacyRemotePath(name))
+	if err != nil {
+		return
+	} else if len(files) > 0 {
+		return fr.legacyRemotePath(name), true, nil
+	}
+
+	// folders named after a repository, or proj's own folders, hold other projects
+	if RepositoryNames == nil || RepositoryNames[name] || name == remoteProjectsFolder || name == remoteNamesFolder {
+		return "", false, nil
+	}
+
+	files, err = s.List(path.Join("/", name))
+	if err != nil {
+		return
+	}
+
+	// a repository's folder only holds project folders, a project has files of its own
+	for _, file := range files {
+		if !strings.Contains(file, "/") {
+			return path.Join("/", name), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+func (fr *ProjectRepository) remoteNamePath(name string) string {
+	return path.Join("/", remoteNamesFolder, fr.name, name)
+}
+
+// isNotFound is true if a StorageService failed because a file doesn't exist
+func isNotFound(err error) bool {
+	return os.IsNotExist(err) || strings.Contains(err.Error(), "not_found")
+}
+
+// remoteId finds the Id a project was uploaded with, it's empty if the project was never uploaded, or was
+// uploaded before it had a persistent Id
+func (fr *ProjectRepository) remoteId(name string, s StorageService) (id string, err error) {
+	tmp, err := ioutil.TempFile("", "proj-id")
+	if err != nil {
+		return
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	err = s.Download(tmp.Name(), fr.remoteNamePath(name))
+	if err != nil {
+		if isNotFound(err) {
+			return "", nil
+		}
+		return
+	}
+
+	data, err := ioutil.ReadFile(tmp.Name())
+	return strings.TrimSpace(string(data)), err
+}
+
+func (fr *ProjectRepository) writeRemoteId(name, id string, s StorageService) (err error) {
+	tmp, err := ioutil.TempFile("", "proj-id")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.WriteString(id + "\n")
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return
+	}
+	return s.Upload(tmp.Name(), fr.remoteNamePath(name))
+}
+
+// remoteFolder finds where a project is stored on a StorageService, projects that have never been uploaded with
+// their persistent Id are in their old, name based, folder
+func (fr *ProjectRepository) remoteFolder(name string, s StorageService) (folder string, err error) {
+	id, err := fr.remoteId(name, s)
+	if err != nil {
+		return
+	}
+
+	if id == "" {
+		legacy, found, err := fr.legacyRemoteFolder(name, s)
+		if err != nil || found {
+			return legacy, err
+		}
+		return fr.legacyRemotePath(name), nil
+	}
+	return path.Join("/", remoteProjectsFolder, id), nil
+}
+
+// uploadFolder works out where to upload a project to on a StorageService. A project that doesn't have a
+// persistent Id yet takes the one it was uploaded with from another machine, and a project that was uploaded
+// before it had a persistent Id is moved into its new folder.
+func (fr *ProjectRepository) uploadFolder(name string, s StorageService) (folder string, err error) {
+	uploadedId, err := fr.remoteId(name, s)
+	if err != nil {
+		return
+	}
+
+	meta, err := fr.Metadata(name)
+	if err != nil {
+		return
+	}
+
+	// a project that doesn't have a persistent Id yet takes the one it was uploaded with from another machine
+	if meta.Id == "" && uploadedId != "" {
+		_, err = fr.migrateId(name, meta, uploadedId)
+		if err != nil {
+			return
+		}
+	}
+
+	id, err := fr.ensureId(name)
+	if err != nil {
+		return
+	}
+
+	folder = path.Join("/", remoteProjectsFolder, id)
+	switch uploadedId {
+	case id:
+		return
+	case "":
+		legacy, found, err := fr.legacyRemoteFolder(name, s)
+		if err != nil {
+			return "", err
+		} else if found {
+			err = s.Move(legacy, folder)
+			if err != nil {
+				return "", err
+			}
+		}
+	default:
+		logrus.Warnf("A different project named %s was uploaded from somewhere else, replacing it", name)
+	}
+
+	if err != nil {
+		return
+	}
+	return folder, fr.writeRemoteId(name, id, s)
+}
@@ -0,0 +1,204 @@
+package proj
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// LocalStorage is a StorageService backed by a folder on this machine (e.g. a mounted NAS or an external drive)
+type LocalStorage struct {
+	root string
+}
+
+// NewLocalStorage creates a StorageService that stores files under root
+func NewLocalStorage(root string) *LocalStorage {
+	return &LocalStorage{root: root}
+}
+
+func (ls *LocalStorage) abs(remote string) string {
+	return filepath.Join(ls.root, filepath.FromSlash(path.Clean("/"+remote)))
+}
+
+func (ls *LocalStorage) WalkDiffs(local, remote string, skip SkipCallback, cb WalkDiffsCallback) error {
+	remoteFolder := ls.abs(remote)
+	remoteFiles := make(map[string]os.FileInfo, 32)
+
+	err := filepath.Walk(remoteFolder, func(file string, info os.FileInfo, walkErr error) (err error) {
+		if walkErr != nil {
+			if os.IsNotExist(walkErr) && file == remoteFolder {
+				return nil
+			}
+			return walkErr
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		strippedFile, err := filepath.Rel(remoteFolder, file)
+		if err != nil {
+			return errors.WithMessage(err, "failed to make remote filepath relative")
+		}
+
+		remoteFiles[strippedFile] = info
+		return
+	})
+
+	if err != nil {
+		return err
+	}
+
+	err = filepath.Walk(local, func(file string, info os.FileInfo, walkErr error) (err error) {
+		if walkErr != nil {
+			return walkErr
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		strippedFile, err := filepath.Rel(local, file)
+		if err != nil {
+			return errors.WithMessage(err, "failed to make local filepath relative")
+		}
+
+		if skip != nil && skip(strippedFile, info) {
+			logrus.Debugf("Skipping %q", strippedFile)
+			return nil
+		}
+
+		logrus.Debugf("Comparing %q", strippedFile)
+
+		remoteInfo, exists := remoteFiles[strippedFile]
+		if !exists {
+			return cb(strippedFile, DiffResultOnlyExistsLocal)
+		}
+		delete(remoteFiles, strippedFile)
+
+		if remoteInfo.Size() != info.Size() {
+			return cb(strippedFile, DiffResultMismatch)
+		}
+
+		localHash, err := hashFile(file)
+		if err != nil {
+			return err
+		}
+
+		remoteHash, err := hashFile(path.Join(remoteFolder, strippedFile))
+		if err != nil {
+			return err
+		}
+
+		if localHash != remoteHash {
+			return cb(strippedFile, DiffResultMismatch)
+		}
+		return cb(strippedFile, DiffResultMatch)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for remotePath := range remoteFiles {
+		err = cb(remotePath, DiffResultOnlyExistsRemote)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ls *LocalStorage) Upload(local, remote string) error {
+	return copyFile(local, ls.abs(remote))
+}
+
+func (ls *LocalStorage) Download(local, remote string) error {
+	return copyFile(ls.abs(remote), local)
+}
+
+func (ls *LocalStorage) Delete(remote string) error {
+	return os.RemoveAll(ls.abs(remote))
+}
+
+func (ls *LocalStorage) Move(from, to string) error {
+	if _, err := os.Stat(ls.abs(from)); os.IsNotExist(err) {
+		return nil
+	}
+
+	err := os.MkdirAll(filepath.Dir(ls.abs(to)), projectFolderPerm)
+	if err != nil {
+		return err
+	}
+	return os.Rename(ls.abs(from), ls.abs(to))
+}
+
+func hashFile(file string) (hash string, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+
+	defer f.Close()
+
+	return ContentHash(f)
+}
+
+func copyFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return
+	}
+
+	err = os.MkdirAll(path.Dir(dst), projectFolderPerm)
+	if err != nil {
+		return
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return
+	}
+
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return
+}
+
+func (ls *LocalStorage) List(remote string) (files []string, err error) {
+	folder := ls.abs(remote)
+	err = filepath.Walk(folder, func(file string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			if os.IsNotExist(walkErr) && file == folder {
+				return nil
+			}
+			return walkErr
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		strippedFile, err := filepath.Rel(folder, file)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, filepath.ToSlash(strippedFile))
+		return nil
+	})
+	return
+}
@@ -0,0 +1,157 @@
+package proj
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MetadataFile is the name of the file, inside each project, that the project's metadata is kept in, it's always
+// JSON
+const MetadataFile = ".proj.json"
+
+// Metadata describes a project, it's kept with the project so it follows it through uploads, backups and exports
+type Metadata struct {
+	// Id identifies the project, it stays the same when the project's renamed or moved to another repository
+	Id string `json:"id,omitempty"`
+
+	// LegacyId is the Id the project had before it was given a persistent one, which was based on its name, it's
+	// kept to find backups made before then
+	LegacyId string `json:"legacy-id,omitempty"`
+
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+
+	// Created is when the project was created by proj, it's nil for projects made some other way. Visits aren't kept
+	// here, they're machine local and recorded in the frecency database
+	Created *time.Time `json:"created,omitempty"`
+
+	// Remote is the remote the project is uploaded to and downloaded from when no remote is given
+	Remote string `json:"remote,omitempty"`
+
+	// Retention overrides the configured retention policy when pruning the project's backups
+	Retention *RetentionPolicy `json:"retention,omitempty"`
+
+	// Env are extra environment variables set when visiting the project
+	Env map[string]string `json:"env,omitempty"`
+
+	// EnvFiles are .env files, relative to the project, that are loaded when visiting the project
+	EnvFiles []string `json:"env-files,omitempty"`
+
+	// Path are folders, relative to the project, added to the start of PATH when visiting the project
+	Path []string `json:"path,omitempty"`
+
+	// OnEnter and OnExit are shell commands run when the project is visited, and left
+	OnEnter []string `json:"on-enter,omitempty"`
+	OnExit  []string `json:"on-exit,omitempty"`
+
+	// TmuxWindows are the windows created in the project's tmux session
+	TmuxWindows []TmuxWindow `json:"tmux-windows,omitempty"`
+}
+
+// HasTag is true if the project is tagged with tag
+func (m *Metadata) HasTag(tag string) bool {
+	for _, t := range m.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// HasTags is true if the project is tagged with every one of tags
+func (m *Metadata) HasTags(tags ...string) bool {
+	for _, tag := range tags {
+		if !m.HasTag(tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// AddTags tags the project, tags it already has are ignored
+func (m *Metadata) AddTags(tags ...string) {
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !m.HasTag(tag) {
+			m.Tags = append(m.Tags, tag)
+		}
+	}
+	sort.Strings(m.Tags)
+}
+
+// RemoveTags removes tags from the project
+func (m *Metadata) RemoveTags(tags ...string) {
+	kept := m.Tags[:0]
+	for _, t := range m.Tags {
+		removed := false
+		for _, tag := range tags {
+			if t == tag {
+				removed = true
+				break
+			}
+		}
+
+		if !removed {
+			kept = append(kept, t)
+		}
+	}
+	m.Tags = kept
+}
+
+func (fr *ProjectRepository) metadataPath(name string) string {
+	return path.Join(fr.Path(name), MetadataFile)
+}
+
+// Metadata reads a project's metadata, projects without a metadata file have empty metadata
+func (fr *ProjectRepository) Metadata(name string) (meta *Metadata, err error) {
+	if _, err := os.Stat(fr.Path(name)); os.IsNotExist(err) {
+		return nil, ErrNoSuchProject
+	} else if err != nil {
+		return nil, err
+	}
+
+	meta = &Metadata{}
+	data, err := ioutil.ReadFile(fr.metadataPath(name))
+	if os.IsNotExist(err) {
+		return meta, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, meta)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// WriteMetadata replaces a project's metadata
+func (fr *ProjectRepository) WriteMetadata(name string, meta *Metadata) (err error) {
+	if _, err := os.Stat(fr.Path(name)); os.IsNotExist(err) {
+		return ErrNoSuchProject
+	} else if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return
+	}
+	return ioutil.WriteFile(fr.metadataPath(name), append(data, '\n'), 0644)
+}
+
+// UpdateMetadata reads a project's metadata, lets update change it, then writes it back
+func (fr *ProjectRepository) UpdateMetadata(name string, update func(meta *Metadata)) (err error) {
+	meta, err := fr.Metadata(name)
+	if err != nil {
+		return
+	}
+
+	update(meta)
+	return fr.WriteMetadata(name, meta)
+}
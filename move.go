@@ -0,0 +1,178 @@
+package proj
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/otiai10/copy"
+	"github.com/sirupsen/logrus"
+)
+
+// moveFolder moves a folder, copying it if it's moved to another filesystem
+func moveFolder(from, to string) (err error) {
+	err = os.MkdirAll(path.Dir(to), projectFolderPerm)
+	if err != nil {
+		return
+	}
+
+	if os.Rename(from, to) == nil {
+		return nil
+	}
+
+	logrus.Debugf("Couldn't rename %s, copying it instead", from)
+	err = copy.Copy(from, to)
+	if err != nil {
+		os.RemoveAll(to)
+		return
+	}
+	return os.RemoveAll(from)
+}
+
+// replaceFolder moves from to to, replacing to entirely instead of merging into it, so no files are left
+// over from the old folder. The old folder is kept aside in a temporary folder until from is in place, and put
+// back if that fails.
+func replaceFolder(from, to string) (err error) {
+	if _, err := os.Stat(to); os.IsNotExist(err) {
+		return moveFolder(from, to)
+	}
+
+	tmpdir, err := ioutil.TempDir("", "proj-replaced")
+	if err != nil {
+		return
+	}
+
+	aside := path.Join(tmpdir, path.Base(to))
+	err = moveFolder(to, aside)
+	if err != nil {
+		os.RemoveAll(tmpdir)
+		return
+	}
+
+	err = moveFolder(from, to)
+	if err != nil {
+		if restoreErr := moveFolder(aside, to); restoreErr != nil {
+			logrus.Errorf("Couldn't put %s back, it's been left in %s: %v", to, aside, restoreErr)
+			return
+		}
+	}
+	os.RemoveAll(tmpdir)
+	return
+}
+
+// Rename renames a project
+func (fr *ProjectRepository) Rename(name, newName string) error {
+	return fr.Move(name, fr, newName)
+}
+
+// Move moves a project to another repository, and gives it a new name, along with its visits, trust and tmux
+// session, its shell history is kept under its Id so it follows it. Its copies on storage services aren't moved,
+// see MoveRemote.
+func (fr *ProjectRepository) Move(name string, to *ProjectRepository, newName string) (err error) {
+	if err = ValidateName(newName); err != nil {
+		return
+	}
+
+	folder, newFolder := fr.Path(name), to.Path(newName)
+	if folder == newFolder {
+		return nil
+	}
+
+	if _, err := os.Stat(folder); os.IsNotExist(err) {
+		return ErrNoSuchProject
+	} else if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(newFolder); err == nil {
+		return ErrProjectExists
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if a, err := to.Archived(newName); err != nil {
+		return err
+	} else if a != nil {
+		return ErrProjectArchived
+	}
+
+	// projects without a persistent Id get one now, since their name based one is about to change
+	id, err := fr.ensureId(name)
+	if err != nil {
+		return
+	}
+
+	logrus.Debugf("Moving %s to %s", folder, newFolder)
+	err = moveFolder(folder, newFolder)
+	if err != nil {
+		return
+	}
+
+	// the project has moved, so nothing below is worth failing over
+
+	folders, err := LoadIdFolders()
+	if err == nil {
+		folders[id] = newFolder
+		err = folders.Write()
+	}
+
+	if err != nil {
+		logrus.Warnf("Couldn't record the project's new folder: %v", err)
+	}
+
+	frecency, err := LoadFrecency()
+	if err == nil {
+		frecency.Rename(fr.name, name, to.name, newName)
+		err = frecency.Write()
+	}
+
+	if err != nil {
+		logrus.Warnf("Couldn't move the project's visits: %v", err)
+	}
+
+	trust, err := LoadTrust()
+	if err == nil {
+		trust.Move(folder, newFolder)
+		err = trust.Write()
+	}
+
+	if err != nil {
+		logrus.Warnf("Couldn't move the project's trust: %v", err)
+	}
+
+	err = renameTmux(tmuxSessionName(name, id), tmuxSessionName(newName, id))
+	if err != nil {
+		logrus.Warnf("Couldn't rename the project's tmux session: %v", err)
+	}
+	return nil
+}
+
+// MoveRemote moves a project's copy on a storage service, after it's been moved with Move, projects are uploaded
+// under their Id, so it's only found under its new name once this is done. Storage services the project was
+// never uploaded to are left alone.
+func (fr *ProjectRepository) MoveRemote(name string, to *ProjectRepository, newName string, s StorageService) error {
+	if fr.remoteNamePath(name) == to.remoteNamePath(newName) {
+		return nil
+	}
+
+	id, err := fr.remoteId(name, s)
+	if err != nil {
+		return err
+	} else if id != "" {
+		return s.Move(fr.remoteNamePath(name), to.remoteNamePath(newName))
+	}
+
+	// projects uploaded before they had a persistent Id are still in a folder named after them
+	legacy, found, err := fr.legacyRemoteFolder(name, s)
+	if err != nil || !found {
+		return err
+	}
+
+	// the folder projects were uploaded to before they were namespaced by repository may hold another
+	// repository's projects, so it's never moved
+	if legacy != fr.legacyRemotePath(name) {
+		logrus.Warnf("%s was uploaded to %s before projects were namespaced by repository, upload it again to copy it to its new name", name, legacy)
+		return nil
+	}
+	return s.Move(legacy, to.legacyRemotePath(newName))
+}
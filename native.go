@@ -0,0 +1,566 @@
+package proj
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// NativeRemotePrefix marks a native repository that's stored on a remote (e.g. "remote:nas")
+const NativeRemotePrefix = "remote:"
+
+// nativeChunkSize is the size of the chunks files are split into, identical chunks are only stored once
+const nativeChunkSize = 1 << 20
+
+var (
+	ErrNoNativeRepos  = errors.New("No native backup repos added")
+	ErrCorruptChunk   = errors.New("Chunk content doesn't match its hash")
+	ErrCorruptBackups = errors.New("Backup repository failed verification")
+	ErrUnsafeManifest = errors.New("Snapshot manifest has a file outside of the snapshot")
+)
+
+// Native is a BackupService that doesn't need any external tools. Files are split into chunks, which are
+// stored by the sha256 of their content, and every snapshot has a manifest listing the chunks of each file.
+//
+// A repository is laid out as
+//
+//	/chunks/{first 2 hex digits}/{sha256 of the chunk}
+//	/snapshots/{snapshot id}.json
+type Native struct {
+	// Open gives the StorageService a repository is kept on, if Open is nil repositories are local folders
+	Open func(repository string) (StorageService, error)
+}
+
+type nativeFile struct {
+	Path    string      `json:"path"`
+	Mode    os.FileMode `json:"mode"`
+	ModTime time.Time   `json:"mtime"`
+	Size    int64       `json:"size"`
+	Chunks  []string    `json:"chunks,omitempty"`
+	Link    string      `json:"link,omitempty"`
+}
+
+type nativeManifest struct {
+	Snapshot
+	Files []nativeFile `json:"files"`
+}
+
+func chunkPath(hash string) string {
+	return path.Join("/chunks", hash[:2], hash)
+}
+
+func snapshotPath(id string) string {
+	return path.Join("/snapshots", id+".json")
+}
+
+func (n Native) open(repository string) (StorageService, error) {
+	if n.Open == nil {
+		return NewLocalStorage(repository), nil
+	}
+	return n.Open(repository)
+}
+
+func (n Native) chunks(s StorageService) (chunks map[string]bool, err error) {
+	files, err := s.List("/chunks")
+	if err != nil {
+		return
+	}
+
+	chunks = make(map[string]bool, len(files))
+	for _, file := range files {
+		chunks[path.Base(file)] = true
+	}
+	return
+}
+
+// backupFile splits a file into chunks, uploading the ones that aren't already stored
+func (n Native) backupFile(s StorageService, file string, stored map[string]bool, tmpdir string) (chunks []string, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	buf := make([]byte, nativeChunkSize)
+	for {
+		size, readErr := io.ReadFull(f, buf)
+		if readErr == io.EOF {
+			break
+		} else if readErr != nil && readErr != io.ErrUnexpectedEOF {
+			return nil, readErr
+		}
+
+		hashed := sha256.Sum256(buf[:size])
+		hash := hex.EncodeToString(hashed[:])
+		chunks = append(chunks, hash)
+
+		if !stored[hash] {
+			tmpChunk := path.Join(tmpdir, hash)
+			err = ioutil.WriteFile(tmpChunk, buf[:size], 0600)
+			if err != nil {
+				return
+			}
+
+			logrus.Debugf("(UPLOAD CHUNK) %s", hash)
+			err = s.Upload(tmpChunk, chunkPath(hash))
+			os.Remove(tmpChunk)
+			if err != nil {
+				return
+			}
+			stored[hash] = true
+		}
+
+		if readErr == io.ErrUnexpectedEOF {
+			break
+		}
+	}
+	return
+}
+
+func (n Native) backup(folder string, tags []string, repository string) (err error) {
+	s, err := n.open(repository)
+	if err != nil {
+		return
+	}
+
+	stored, err := n.chunks(s)
+	if err != nil {
+		return
+	}
+
+	tmpdir, err := ioutil.TempDir("", "proj-native-backup")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(tmpdir)
+
+	hostname, _ := os.Hostname()
+	manifest := nativeManifest{
+		Snapshot: Snapshot{
+			Time:     time.Now(),
+			Paths:    []string{folder},
+			Tags:     tags,
+			Hostname: hostname,
+		},
+		Files: make([]nativeFile, 0, 32),
+	}
+
+	err = filepath.Walk(folder, func(file string, info os.FileInfo, walkErr error) (err error) {
+		if walkErr != nil {
+			return walkErr
+		}
+
+		strippedFile, err := filepath.Rel(folder, file)
+		if err != nil {
+			return err
+		}
+
+		entry := nativeFile{
+			Path:    filepath.ToSlash(strippedFile),
+			Mode:    info.Mode(),
+			ModTime: info.ModTime(),
+			Size:    info.Size(),
+		}
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			entry.Link, err = os.Readlink(file)
+		case info.Mode().IsRegular():
+			entry.Chunks, err = n.backupFile(s, file, stored, tmpdir)
+		case info.IsDir():
+			entry.Size = 0
+		default:
+			logrus.Debugf("Skipping special file %q", strippedFile)
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		manifest.Files = append(manifest.Files, entry)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return
+	}
+
+	hashed := sha256.Sum256(data)
+	manifest.ID = hex.EncodeToString(hashed[:])
+	manifest.ShortID = manifest.ID[:8]
+
+	data, err = json.Marshal(manifest)
+	if err != nil {
+		return
+	}
+
+	tmpManifest := path.Join(tmpdir, manifest.ID+".json")
+	err = ioutil.WriteFile(tmpManifest, data, 0600)
+	if err != nil {
+		return
+	}
+
+	logrus.Infof("Saving snapshot %s of %s to %s", manifest.ShortID, folder, repository)
+	return s.Upload(tmpManifest, snapshotPath(manifest.ID))
+}
+
+// Backup a folder to every repository
+func (n Native) Backup(folder string, tags []string, repos ...string) (err error) {
+	if len(repos) == 0 {
+		return ErrNoNativeRepos
+	}
+
+	for _, repo := range repos {
+		err = n.backup(folder, tags, repo)
+		if err != nil {
+			return err
+		}
+	}
+	return
+}
+
+func (n Native) manifests(s StorageService) (manifests []nativeManifest, err error) {
+	files, err := s.List("/snapshots")
+	if err != nil {
+		return
+	}
+
+	tmpdir, err := ioutil.TempDir("", "proj-native-snapshots")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(tmpdir)
+
+	manifests = make([]nativeManifest, 0, len(files))
+	for _, file := range files {
+		if !strings.HasSuffix(file, ".json") {
+			continue
+		}
+
+		local := path.Join(tmpdir, path.Base(file))
+		err = s.Download(local, path.Join("/snapshots", file))
+		if err != nil {
+			return
+		}
+
+		data, err := ioutil.ReadFile(local)
+		if err != nil {
+			return nil, err
+		}
+
+		manifest := nativeManifest{}
+		err = json.Unmarshal(data, &manifest)
+		if err != nil {
+			return nil, errors.WithMessage(err, "while reading snapshot "+file)
+		}
+		manifests = append(manifests, manifest)
+	}
+
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].Time.Before(manifests[j].Time)
+	})
+	return
+}
+
+// Snapshots lists the snapshots labeled with any of tags, or the unlabeled snapshots of folder, oldest first
+func (n Native) Snapshots(folder, repository string, tags []string) (snapshots []Snapshot, err error) {
+	s, err := n.open(repository)
+	if err != nil {
+		return
+	}
+
+	manifests, err := n.manifests(s)
+	if err != nil {
+		return
+	}
+
+	snapshots = make([]Snapshot, len(manifests))
+	for i, manifest := range manifests {
+		snapshots[i] = manifest.Snapshot
+	}
+	return filterSnapshots(snapshots, folder, tags), nil
+}
+
+// findManifest finds a snapshot's manifest
+func (n Native) findManifest(s StorageService, snapshot Snapshot) (found nativeManifest, err error) {
+	manifests, err := n.manifests(s)
+	if err != nil {
+		return
+	}
+
+	for _, manifest := range manifests {
+		if manifest.ID == snapshot.ID {
+			return manifest, nil
+		}
+	}
+	return found, ErrNoSuchSnapshot
+}
+
+// downloadChunk downloads a chunk and checks its content against its hash
+func downloadChunk(s StorageService, hash, local string) (data []byte, err error) {
+	err = s.Download(local, chunkPath(hash))
+	if err != nil {
+		return
+	}
+
+	data, err = ioutil.ReadFile(local)
+	os.Remove(local)
+	if err != nil {
+		return
+	}
+
+	hashed := sha256.Sum256(data)
+	if hex.EncodeToString(hashed[:]) != hash {
+		return nil, errors.WithMessage(ErrCorruptChunk, hash)
+	}
+	return
+}
+
+func (n Native) restoreFile(s StorageService, file nativeFile, dest, tmpdir string) (err error) {
+	switch {
+	case file.Mode.IsDir():
+		err = os.MkdirAll(dest, file.Mode.Perm())
+		if err != nil {
+			return
+		}
+		return os.Chmod(dest, file.Mode.Perm())
+	case file.Mode&os.ModeSymlink != 0:
+		return os.Symlink(file.Link, dest)
+	}
+
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, file.Mode.Perm())
+	if err != nil {
+		return
+	}
+
+	for _, hash := range file.Chunks {
+		data, err := downloadChunk(s, hash, path.Join(tmpdir, hash))
+		if err != nil {
+			f.Close()
+			return err
+		}
+
+		_, err = f.Write(data)
+		if err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	err = f.Close()
+	if err != nil {
+		return
+	}
+	return os.Chtimes(dest, file.ModTime, file.ModTime)
+}
+
+// Restore the latest snapshot of folder
+func (n Native) Restore(folder, repository string, tags []string) error {
+	return restoreLatest(n, folder, repository, tags)
+}
+
+// RestoreSnapshot restores a snapshot into target
+func (n Native) RestoreSnapshot(repository string, snapshot Snapshot, target string) (err error) {
+	s, err := n.open(repository)
+	if err != nil {
+		return
+	}
+
+	manifest, err := n.findManifest(s, snapshot)
+	if err != nil {
+		return
+	}
+
+	tmpdir, err := ioutil.TempDir("", "proj-native-restore")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(tmpdir)
+
+	restored := path.Join(tmpdir, "restored")
+	chunkdir := path.Join(tmpdir, "chunks")
+	err = os.MkdirAll(chunkdir, 0700)
+	if err != nil {
+		return
+	}
+
+	logrus.Infof("Restoring snapshot %s of %s to %s", manifest.ShortID, strings.Join(manifest.Paths, ", "), target)
+
+	// directories are restored last, so their permissions don't prevent writing the files inside them
+	dirs := make([]nativeFile, 0, 8)
+	for _, file := range manifest.Files {
+		// manifests are read from the repository, so a file can't be trusted to stay inside the snapshot
+		rel := path.Clean(file.Path)
+		if rel == ".." || strings.HasPrefix(rel, "../") || path.IsAbs(rel) {
+			return errors.WithMessage(ErrUnsafeManifest, file.Path)
+		}
+
+		dest := filepath.Join(restored, filepath.FromSlash(rel))
+		var linked bool
+		linked, err = throughSymlink(restored, dest)
+		if err != nil {
+			return err
+		} else if linked {
+			return errors.WithMessage(ErrUnsafeManifest, "file written through a symlink "+file.Path)
+		}
+
+		file.Path = rel
+		if file.Mode.IsDir() {
+			dirs = append(dirs, file)
+			err = os.MkdirAll(dest, 0700)
+		} else {
+			err = os.MkdirAll(path.Dir(dest), 0700)
+			if err == nil {
+				err = n.restoreFile(s, file, dest, chunkdir)
+			}
+		}
+
+		if err != nil {
+			return errors.WithMessage(err, "while restoring "+file.Path)
+		}
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		err = n.restoreFile(s, dirs[i], filepath.Join(restored, filepath.FromSlash(dirs[i].Path)), chunkdir)
+		if err != nil {
+			return
+		}
+	}
+
+	return replaceFolder(restored, target)
+}
+
+// Prune removes the snapshots labeled with every one of tags that policy doesn't keep, then the chunks no snapshot
+// uses anymore
+func (n Native) Prune(repository string, tags []string, policy RetentionPolicy) (err error) {
+	if policy.IsZero() {
+		return ErrEmptyRetentionPolicy
+	}
+
+	s, err := n.open(repository)
+	if err != nil {
+		return
+	}
+
+	manifests, err := n.manifests(s)
+	if err != nil {
+		return
+	}
+
+	snapshots := make([]Snapshot, len(manifests))
+	for i, manifest := range manifests {
+		snapshots[i] = manifest.Snapshot
+	}
+
+	forget := make(map[string]bool)
+	for _, snapshot := range pruneSelect(snapshots, tags, policy) {
+		logrus.Infof("Removing snapshot %s", snapshot.ShortID)
+		err = s.Delete(snapshotPath(snapshot.ID))
+		if err != nil {
+			return
+		}
+		forget[snapshot.ID] = true
+	}
+
+	if len(forget) == 0 {
+		return nil
+	}
+
+	used := make(map[string]bool)
+	for _, manifest := range manifests {
+		if forget[manifest.ID] {
+			continue
+		}
+
+		for _, file := range manifest.Files {
+			for _, hash := range file.Chunks {
+				used[hash] = true
+			}
+		}
+	}
+
+	chunks, err := n.chunks(s)
+	if err != nil {
+		return
+	}
+
+	removed := 0
+	for hash := range chunks {
+		if used[hash] {
+			continue
+		}
+
+		err = s.Delete(chunkPath(hash))
+		if err != nil {
+			return
+		}
+		removed++
+	}
+
+	logrus.Infof("Removed %d snapshots and %d chunks", len(forget), removed)
+	return nil
+}
+
+// Verify checks that every chunk used by the snapshots in a repository exists and hasn't been corrupted
+func (n Native) Verify(repository string) (err error) {
+	s, err := n.open(repository)
+	if err != nil {
+		return
+	}
+
+	manifests, err := n.manifests(s)
+	if err != nil {
+		return
+	}
+
+	tmpdir, err := ioutil.TempDir("", "proj-native-verify")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(tmpdir)
+
+	checked := make(map[string]bool)
+	failed := 0
+	for _, manifest := range manifests {
+		for _, file := range manifest.Files {
+			for _, hash := range file.Chunks {
+				if checked[hash] {
+					continue
+				}
+				checked[hash] = true
+
+				_, err = downloadChunk(s, hash, path.Join(tmpdir, hash))
+				if err != nil {
+					failed++
+					logrus.
+						WithField("Snapshot", manifest.ShortID).
+						WithField("File", file.Path).
+						WithError(err).
+						Error("Bad chunk")
+				}
+			}
+		}
+	}
+
+	logrus.Infof("Checked %d chunks in %d snapshots", len(checked), len(manifests))
+	if failed > 0 {
+		return ErrCorruptBackups
+	}
+	return nil
+}
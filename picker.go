@@ -0,0 +1,230 @@
+package proj
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var (
+	// ErrPickCancelled is returned by Pick if the user didn't pick anything
+	ErrPickCancelled = errors.New("Nothing was picked")
+
+	// ErrNothingToPick is returned by Pick if it's given no items
+	ErrNothingToPick = errors.New("Nothing to pick from")
+)
+
+// PickerItem is one of the items a user can pick from
+type PickerItem struct {
+	// Label is shown in the list, and matched against what the user types
+	Label string
+
+	// Preview gives the lines shown for the highlighted item, it's called at most once per item, and can be nil
+	Preview func() []string
+}
+
+type picker struct {
+	tty    *os.File
+	prompt string
+	items  []PickerItem
+
+	query    []rune
+	matches  []int
+	selected int
+	offset   int
+	previews map[int][]string
+}
+
+// filter finds the items matching the query, best matches first, items that match equally well keep their order
+func (p *picker) filter() {
+	query := string(p.query)
+	p.matches = p.matches[:0]
+	quality := make(map[int]int, len(p.items))
+	for i, item := range p.items {
+		if q := matchQuality(query, item.Label); q > 0 || query == "" {
+			p.matches = append(p.matches, i)
+			quality[i] = q
+		}
+	}
+
+	sort.SliceStable(p.matches, func(i, j int) bool {
+		return quality[p.matches[i]] > quality[p.matches[j]]
+	})
+	p.selected, p.offset = 0, 0
+}
+
+func (p *picker) move(by int) {
+	p.selected += by
+	if p.selected >= len(p.matches) {
+		p.selected = len(p.matches) - 1
+	}
+	if p.selected < 0 {
+		p.selected = 0
+	}
+}
+
+// truncate cuts a line to fit the terminal
+func truncate(line string, width int) string {
+	line = strings.Replace(line, "\t", "    ", -1)
+	if utf8.RuneCountInString(line) <= width {
+		return line
+	}
+
+	runes := []rune(line)
+	if width < 1 {
+		return ""
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+func (p *picker) preview(item int) []string {
+	if lines, cached := p.previews[item]; cached {
+		return lines
+	}
+
+	var lines []string
+	if p.items[item].Preview != nil {
+		lines = p.items[item].Preview()
+	}
+	p.previews[item] = lines
+	return lines
+}
+
+func (p *picker) draw() {
+	width, height, err := terminal.GetSize(int(p.tty.Fd()))
+	if err != nil || width < 1 || height < 1 {
+		width, height = 80, 24
+	}
+
+	// the list gets the top half of the screen, below the prompt, and the preview gets the rest
+	listHeight := (height - 2) / 2
+	if listHeight < 1 {
+		listHeight = 1
+	}
+
+	if p.selected < p.offset {
+		p.offset = p.selected
+	} else if p.selected >= p.offset+listHeight {
+		p.offset = p.selected - listHeight + 1
+	}
+
+	header := truncate(fmt.Sprintf("%s (%d/%d) > %s", p.prompt, len(p.matches), len(p.items), string(p.query)), width)
+	out := &strings.Builder{}
+	out.WriteString("\033[H\033[2J")
+	out.WriteString(header + "\r\n")
+
+	for row := 0; row < listHeight; row++ {
+		i := p.offset + row
+		if i < len(p.matches) {
+			label := truncate("  "+p.items[p.matches[i]].Label, width)
+			if i == p.selected {
+				label = "\033[7m" + truncate("> "+p.items[p.matches[i]].Label, width) + "\033[0m"
+			}
+			out.WriteString(label)
+		}
+		out.WriteString("\r\n")
+	}
+
+	out.WriteString(strings.Repeat("─", width) + "\r\n")
+	if len(p.matches) > 0 {
+		lines := p.preview(p.matches[p.selected])
+		for row := 0; row < height-listHeight-3 && row < len(lines); row++ {
+			out.WriteString(truncate(lines[row], width) + "\r\n")
+		}
+	}
+
+	// put the cursor at the end of the query
+	fmt.Fprintf(out, "\033[1;%dH", utf8.RuneCountInString(header)+1)
+	p.tty.WriteString(out.String())
+}
+
+// Pick lets the user pick one of several items, by typing to filter them and moving through them with the arrow
+// keys, it returns the index of the picked item. The picker is drawn on the terminal itself, not stdout, so it can
+// be used when stdout is redirected.
+func Pick(prompt string, items []PickerItem) (choice int, err error) {
+	if len(items) == 0 {
+		return -1, ErrNothingToPick
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return -1, errors.WithMessage(err, "while opening the terminal")
+	}
+	defer tty.Close()
+
+	state, err := terminal.MakeRaw(int(tty.Fd()))
+	if err != nil {
+		return -1, err
+	}
+	defer terminal.Restore(int(tty.Fd()), state)
+
+	// draw on the alternate screen, so the picker disappears once it's done
+	tty.WriteString("\033[?1049h")
+	defer tty.WriteString("\033[?1049l")
+
+	p := &picker{
+		tty:      tty,
+		prompt:   prompt,
+		items:    items,
+		matches:  make([]int, 0, len(items)),
+		previews: make(map[int][]string),
+	}
+	p.filter()
+
+	buf := make([]byte, 64)
+	for {
+		p.draw()
+
+		n, err := tty.Read(buf)
+		if err != nil {
+			return -1, err
+		}
+
+		key := string(buf[:n])
+		switch key {
+		case "\r", "\n":
+			if len(p.matches) > 0 {
+				return p.matches[p.selected], nil
+			}
+		case "\033", "\x03", "\x04", "\x07":
+			// escape, ctrl-c, ctrl-d, ctrl-g
+			return -1, ErrPickCancelled
+		case "\033[A", "\033OA", "\x10":
+			// up, ctrl-p
+			p.move(-1)
+		case "\033[B", "\033OB", "\x0e":
+			// down, ctrl-n
+			p.move(1)
+		case "\033[5~":
+			p.move(-10)
+		case "\033[6~":
+			p.move(10)
+		case "\x7f", "\x08":
+			// backspace
+			if len(p.query) > 0 {
+				p.query = p.query[:len(p.query)-1]
+				p.filter()
+			}
+		case "\x15":
+			// ctrl-u
+			p.query = p.query[:0]
+			p.filter()
+		default:
+			if strings.HasPrefix(key, "\033") {
+				continue
+			}
+
+			for _, r := range key {
+				if r >= ' ' {
+					p.query = append(p.query, r)
+				}
+			}
+			p.filter()
+		}
+	}
+}
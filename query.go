@@ -0,0 +1,371 @@
+package proj
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrUnknownSortKey   = errors.New("Unknown sort key, expected name, mtime, size or recent")
+	ErrInvalidTimespan  = errors.New("Invalid time, expected a date (2006-01-02), or an age (e.g. 90m, 12h, 7d, 2w)")
+	ErrEmptyAlternative = errors.New("Invalid query, OR needs terms on both sides")
+)
+
+// Query selects projects, it's a list of alternatives, a project matches if it matches every term of any one
+// of them. Terms are separated by spaces, alternatives by OR (AND can be written, but is implied), a term is
+//
+//	REGEX           the name matches a regular expression
+//	re:REGEX        the same, for regular expressions that look like another kind of term
+//	glob:PATTERN    the name matches a shell pattern, e.g. glob:go-*
+//	fuzzy:CHARS     the name contains CHARS in order, ignoring case, e.g. fuzzy:gpj matches go-proj
+//	tag:TAG         the project is tagged with TAG
+//	mtime:FROM..TO  a file in the project was modified between FROM and TO, either can be left out, and each
+//	                is a date (2006-01-02) or an age (90m, 12h, 7d, 2w), mtime:7d is short for mtime:7d..
+//
+// and any term can be negated with a leading !
+type Query [][]queryTerm
+
+type queryTerm struct {
+	negated bool
+	match   func(p *ProjectStat) (bool, error)
+}
+
+// ParseQuery parses a query, every argument can hold several terms
+func ParseQuery(args ...string) (q Query, err error) {
+	group := []queryTerm{}
+	for _, arg := range args {
+		for _, field := range strings.Fields(arg) {
+			switch field {
+			case "AND", "and", "&&":
+				continue
+			case "OR", "or", "||":
+				if len(group) == 0 {
+					return nil, ErrEmptyAlternative
+				}
+				q = append(q, group)
+				group = []queryTerm{}
+				continue
+			}
+
+			term, err := parseQueryTerm(field)
+			if err != nil {
+				return nil, errors.WithMessage(err, field)
+			}
+			group = append(group, term)
+		}
+	}
+	if len(group) == 0 && len(q) > 0 {
+		return nil, ErrEmptyAlternative
+	}
+	return append(q, group), nil
+}
+
+func parseQueryTerm(field string) (term queryTerm, err error) {
+	if strings.HasPrefix(field, "!") && len(field) > 1 {
+		term.negated = true
+		field = field[1:]
+	}
+
+	kind, arg := "re", field
+	if i := strings.IndexByte(field, ':'); i > 0 {
+		switch field[:i] {
+		case "re", "glob", "fuzzy", "tag", "mtime":
+			kind, arg = field[:i], field[i+1:]
+		}
+	}
+
+	switch kind {
+	case "re":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return term, err
+		}
+		term.match = func(p *ProjectStat) (bool, error) {
+			return re.MatchString(p.Name), nil
+		}
+	case "glob":
+		if _, err := filepath.Match(arg, ""); err != nil {
+			return term, err
+		}
+		term.match = func(p *ProjectStat) (bool, error) {
+			return filepath.Match(arg, p.Name)
+		}
+	case "fuzzy":
+		term.match = func(p *ProjectStat) (bool, error) {
+			return fuzzyMatch(arg, p.Name), nil
+		}
+	case "tag":
+		term.match = func(p *ProjectStat) (bool, error) {
+			meta, err := p.Metadata()
+			if err != nil {
+				return false, err
+			}
+			return meta.HasTag(arg), nil
+		}
+	case "mtime":
+		from, to, err := parseTimespan(arg)
+		if err != nil {
+			return term, err
+		}
+		term.match = func(p *ProjectStat) (bool, error) {
+			mtime, err := p.ModTime()
+			if err != nil {
+				return false, err
+			}
+			return !mtime.Before(from) && (to.IsZero() || mtime.Before(to)), nil
+		}
+	}
+	return
+}
+
+// fuzzyMatch is true if every character of pattern appears in s, in the same order, ignoring case
+func fuzzyMatch(pattern, s string) bool {
+	s = strings.ToLower(s)
+	for _, c := range strings.ToLower(pattern) {
+		i := strings.IndexRune(s, c)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(string(c)):]
+	}
+	return true
+}
+
+// ParseTime parses a date, or an age like 7d which is that long before now
+func ParseTime(s string) (t time.Time, err error) {
+	for _, layout := range []string{"2006-01-02", "2006-01-02T15:04", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	if len(s) < 2 {
+		return t, ErrInvalidTimespan
+	}
+
+	unit := time.Duration(0)
+	switch s[len(s)-1] {
+	case 'm':
+		unit = time.Minute
+	case 'h':
+		unit = time.Hour
+	case 'd':
+		unit = 24 * time.Hour
+	case 'w':
+		unit = 7 * 24 * time.Hour
+	default:
+		return t, ErrInvalidTimespan
+	}
+
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n < 0 {
+		return t, ErrInvalidTimespan
+	}
+	return time.Now().Add(-time.Duration(n) * unit), nil
+}
+
+// parseTimespan parses FROM..TO, a zero to means there's no upper bound
+func parseTimespan(span string) (from, to time.Time, err error) {
+	bounds := strings.SplitN(span, "..", 2)
+	if bounds[0] != "" {
+		from, err = ParseTime(bounds[0])
+		if err != nil {
+			return
+		}
+	}
+
+	if len(bounds) == 2 && bounds[1] != "" {
+		to, err = ParseTime(bounds[1])
+	}
+	return
+}
+
+// Matches is true if a project matches the query, an empty query matches every project
+func (q Query) Matches(p *ProjectStat) (bool, error) {
+	for _, group := range q {
+		matched := true
+		for _, term := range group {
+			ok, err := term.match(p)
+			if err != nil {
+				return false, err
+			}
+
+			if ok == term.negated {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return true, nil
+		}
+	}
+	return len(q) == 0, nil
+}
+
+// ProjectStat describes a project, the expensive parts are only worked out when they're first needed
+type ProjectStat struct {
+	Repo *ProjectRepository
+	Name string
+
+	// Archive is the project's archive record, if it's archived, archived projects are described as they were
+	// when they were archived
+	Archive *ArchivedProject
+
+	meta    *Metadata
+	modTime time.Time
+	size    int64
+	walked  bool
+}
+
+// Stat describes a project
+func (fr *ProjectRepository) Stat(name string) *ProjectStat {
+	return &ProjectStat{Repo: fr, Name: name}
+}
+
+func (p *ProjectStat) Path() string {
+	return p.Repo.Path(p.Name)
+}
+
+// Metadata is the project's metadata
+func (p *ProjectStat) Metadata() (meta *Metadata, err error) {
+	if p.meta == nil {
+		p.meta, err = p.Repo.Metadata(p.Name)
+	}
+	return p.meta, err
+}
+
+// walk finds the size and last modification time of the project
+func (p *ProjectStat) walk() (err error) {
+	if p.walked {
+		return
+	}
+
+	err = filepath.Walk(p.Path(), func(file string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+
+		if info.Name() == MetadataFile {
+			// changing a project's metadata, e.g. tagging it, isn't a modification of the project
+			return nil
+		}
+
+		if info.ModTime().After(p.modTime) {
+			p.modTime = info.ModTime()
+		}
+
+		if info.Mode().IsRegular() {
+			p.size += info.Size()
+		}
+		return nil
+	})
+	p.walked = err == nil
+	return
+}
+
+// ModTime is when any file in the project was last modified
+func (p *ProjectStat) ModTime() (time.Time, error) {
+	err := p.walk()
+	return p.modTime, err
+}
+
+// Size is the total size of the project's files, in bytes
+func (p *ProjectStat) Size() (int64, error) {
+	err := p.walk()
+	return p.size, err
+}
+
+// Query lists the projects matching a query. Hidden folders aren't projects, they're where proj unpacks imports
+// before moving them into place, so they're skipped.
+func (fr *ProjectRepository) Query(q Query) (matches []*ProjectStat, err error) {
+	finfo, err := ioutil.ReadDir(fr.Path(""))
+	if err != nil {
+		return
+	}
+
+	matches = make([]*ProjectStat, 0, len(finfo))
+	for _, f := range finfo {
+		if !f.IsDir() || strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+
+		p := fr.Stat(f.Name())
+		ok, err := q.Matches(p)
+		if err != nil {
+			return nil, errors.WithMessage(err, p.Name)
+		}
+
+		if ok {
+			matches = append(matches, p)
+		}
+	}
+	return
+}
+
+// SortKeys lists the ways projects can be sorted
+var SortKeys = []string{"name", "mtime", "size", "recent"}
+
+// SortProjects sorts projects by name, or by most recently modified, largest, or most recently visited first
+func SortProjects(projects []*ProjectStat, by string) (err error) {
+	var less func(a, b *ProjectStat) (bool, error)
+
+	switch by {
+	case "", "name":
+		less = func(a, b *ProjectStat) (bool, error) {
+			if a.Name == b.Name {
+				return a.Repo.Name() < b.Repo.Name(), nil
+			}
+			return a.Name < b.Name, nil
+		}
+	case "mtime":
+		less = func(a, b *ProjectStat) (bool, error) {
+			at, err := a.ModTime()
+			if err != nil {
+				return false, err
+			}
+			bt, err := b.ModTime()
+			return at.After(bt), err
+		}
+	case "size":
+		less = func(a, b *ProjectStat) (bool, error) {
+			as, err := a.Size()
+			if err != nil {
+				return false, err
+			}
+			bs, err := b.Size()
+			return as > bs, err
+		}
+	case "recent":
+		frecency, err := LoadFrecency()
+		if err != nil {
+			return err
+		}
+
+		less = func(a, b *ProjectStat) (bool, error) {
+			at := frecency.Get(a.Repo.Name(), a.Name).Last
+			return at.After(frecency.Get(b.Repo.Name(), b.Name).Last), nil
+		}
+	default:
+		return ErrUnknownSortKey
+	}
+
+	sort.SliceStable(projects, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+
+		var ok bool
+		ok, err = less(projects[i], projects[j])
+		return ok
+	})
+	return
+}
@@ -0,0 +1,116 @@
+package proj
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestParseQuery(t *testing.T) {
+	now := time.Now()
+	projects := []*ProjectStat{
+		{Name: "go-proj", meta: &Metadata{Tags: []string{"go", "cli"}}, modTime: now.Add(-time.Hour), walked: true},
+		{Name: "go-service", meta: &Metadata{Tags: []string{"go"}}, modTime: now.Add(-10 * 24 * time.Hour), walked: true},
+		{Name: "website", meta: &Metadata{Tags: []string{"js"}}, modTime: time.Date(2019, 6, 1, 12, 0, 0, 0, time.Local), walked: true},
+		{Name: "old.notes", meta: &Metadata{}, modTime: time.Date(2015, 1, 1, 12, 0, 0, 0, time.Local), walked: true},
+	}
+
+	for _, test := range []struct {
+		args    []string
+		matches []string
+	}{
+		{nil, []string{"go-proj", "go-service", "website", "old.notes"}},
+		{[]string{"^go-"}, []string{"go-proj", "go-service"}},
+		{[]string{"re:^web"}, []string{"website"}},
+		{[]string{"glob:*-s*"}, []string{"go-service"}},
+		{[]string{"glob:old.*"}, []string{"old.notes"}},
+		{[]string{"fuzzy:GPJ"}, []string{"go-proj"}},
+		{[]string{"fuzzy:jpg"}, []string{}},
+		{[]string{"tag:go"}, []string{"go-proj", "go-service"}},
+		{[]string{"!tag:go"}, []string{"website", "old.notes"}},
+		{[]string{"mtime:7d"}, []string{"go-proj"}},
+		{[]string{"mtime:..7d"}, []string{"go-service", "website", "old.notes"}},
+		{[]string{"mtime:2019-01-01..2020-01-01"}, []string{"website"}},
+		{[]string{"mtime:..2016-01-01"}, []string{"old.notes"}},
+		{[]string{"tag:go !tag:cli"}, []string{"go-service"}},
+		{[]string{"tag:go", "AND", "!tag:cli"}, []string{"go-service"}},
+		{[]string{"tag:cli OR tag:js"}, []string{"go-proj", "website"}},
+		{[]string{"tag:cli", "||", "glob:old.*"}, []string{"go-proj", "old.notes"}},
+		{[]string{"tag:js or fuzzy:svc !proj"}, []string{"go-service", "website"}},
+		{[]string{"!"}, []string{}},
+	} {
+		q, err := ParseQuery(test.args...)
+		if err != nil {
+			t.Errorf("%q: %v", test.args, err)
+			continue
+		}
+
+		matches := []string{}
+		for _, p := range projects {
+			ok, err := q.Matches(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ok {
+				matches = append(matches, p.Name)
+			}
+		}
+
+		if len(matches) != len(test.matches) {
+			t.Errorf("%q: expected %q to match, got %q", test.args, test.matches, matches)
+			continue
+		}
+
+		for i := range matches {
+			if matches[i] != test.matches[i] {
+				t.Errorf("%q: expected %q to match, got %q", test.args, test.matches, matches)
+				break
+			}
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, test := range []struct {
+		args []string
+		err  error
+	}{
+		{[]string{"OR tag:go"}, ErrEmptyAlternative},
+		{[]string{"tag:go OR"}, ErrEmptyAlternative},
+		{[]string{"tag:go", "OR", "OR", "tag:js"}, ErrEmptyAlternative},
+		{[]string{"mtime:yesterday"}, ErrInvalidTimespan},
+		{[]string{"mtime:7y"}, ErrInvalidTimespan},
+		{[]string{"mtime:-3d"}, ErrInvalidTimespan},
+		{[]string{"re:("}, nil},
+		{[]string{"glob:["}, nil},
+	} {
+		_, err := ParseQuery(test.args...)
+		if err == nil {
+			t.Errorf("%q: expected an error", test.args)
+		} else if test.err != nil && errors.Cause(err) != test.err {
+			t.Errorf("%q: expected %v, got %v", test.args, test.err, err)
+		}
+	}
+}
+
+func TestQuerySkipsHiddenFolders(t *testing.T) {
+	fr, cleanupRepo := testRepository(t)
+	defer cleanupRepo()
+
+	err := os.Mkdir(fr.Path(".proj-import123"), projectFolderPerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := fr.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(names) != 1 || names[0] != "foo" {
+		t.Errorf("expected only foo to be listed, got %q", names)
+	}
+}
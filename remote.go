@@ -0,0 +1,51 @@
+package proj
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	// RemoteTypeDropbox is a remote that stores projects in a Dropbox account
+	RemoteTypeDropbox = "dropbox"
+
+	// RemoteTypeLocal is a remote that stores projects in a folder on this machine
+	RemoteTypeLocal = "local"
+)
+
+var (
+	ErrNoSuchRemote      = errors.New("No such remote")
+	ErrUnknownRemoteType = errors.New("Unknown remote type")
+	ErrRemoteNotLoggedIn = errors.New("Remote is missing an access token, login first")
+	ErrRemoteMissingRoot = errors.New("Remote is missing a root path")
+)
+
+// RemoteTypes lists every supported remote type
+var RemoteTypes = []string{RemoteTypeDropbox, RemoteTypeLocal}
+
+// Remote is a named storage location, along with the credentials needed to access it
+type Remote struct {
+	Type string `json:"type"`
+	Root string `json:"root,omitempty"`
+
+	AppKey    string `json:"app-key,omitempty"`
+	AppSecret string `json:"app-secret,omitempty"`
+	Token     string `json:"token,omitempty"`
+}
+
+// Open creates a StorageService that can be used to access the remote
+func (r *Remote) Open() (StorageService, error) {
+	switch r.Type {
+	case RemoteTypeDropbox:
+		if r.Token == "" {
+			return nil, ErrRemoteNotLoggedIn
+		}
+		return NewDropbox(r.Token, r.Root), nil
+	case RemoteTypeLocal:
+		if r.Root == "" {
+			return nil, ErrRemoteMissingRoot
+		}
+		return NewLocalStorage(r.Root), nil
+	default:
+		return nil, errors.WithMessage(ErrUnknownRemoteType, r.Type)
+	}
+}
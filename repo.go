@@ -1,15 +1,14 @@
 package proj
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path"
-	"regexp"
+	"sort"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/pkg/errors"
 	gitignore "github.com/sabhiram/go-gitignore"
@@ -20,45 +19,66 @@ const projectFolderPerm = 0775
 
 var (
 	ErrNoSuchProject = errors.New("No such project")
+	ErrProjectExists = errors.New("Project already exists")
+	ErrInvalidName   = errors.New("Invalid project name, names can't be empty, start with a ., or contain a /")
 )
 
+// ValidateName checks a project name refers to a folder directly inside a repository
+func ValidateName(name string) error {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, "/\\") || path.IsAbs(name) {
+		return errors.WithMessage(ErrInvalidName, name)
+	}
+	return nil
+}
+
 type ProjectRepository struct {
+	name        string
 	baseFolder  string
 	interactive bool
 }
 
+// modEnviron returns this process's environment, with the variables in newVars added or replaced
 func modEnviron(newVars map[string]string) []string {
 	env := os.Environ()
-	env2 := env
-	env = env[:0]
-
-	for _, v := range env2 {
-		for newVar, newVal := range newVars {
-			if len(newVar) < len(v) && strings.HasPrefix(newVar, v) && v[len(newVar)] == '=' {
-				env = append(env, newVar+"="+newVal)
-				delete(newVars, newVar)
-				break
+	modified := make([]string, 0, len(env)+len(newVars))
+	replaced := make(map[string]bool, len(newVars))
+
+	for _, v := range env {
+		name := v
+		if i := strings.IndexByte(v, '='); i >= 0 {
+			name = v[:i]
+		}
+
+		if newVal, exists := newVars[name]; exists {
+			if !replaced[name] {
+				modified = append(modified, name+"="+newVal)
+				replaced[name] = true
 			}
+			continue
 		}
-		env = append(env, v)
+		modified = append(modified, v)
 	}
 
 	for newVar, newVal := range newVars {
-		env = append(env, newVar+"="+newVal)
+		if !replaced[newVar] {
+			modified = append(modified, newVar+"="+newVal)
+		}
 	}
 
-	return env
+	return modified
 }
 
-func NewLocal(base string) *ProjectRepository {
+func NewLocal(name, base string) *ProjectRepository {
 	return &ProjectRepository{
+		name:        name,
 		baseFolder:  base,
 		interactive: false,
 	}
 }
 
-func NewInteractiveLocal(base string) *ProjectRepository {
+func NewInteractiveLocal(name, base string) *ProjectRepository {
 	return &ProjectRepository{
+		name:        name,
 		baseFolder:  base,
 		interactive: true,
 	}
@@ -68,12 +88,9 @@ func (fr *ProjectRepository) Path(name string) string {
 	return path.Join(fr.baseFolder, name)
 }
 
-func (fr *ProjectRepository) Id(name string) string {
-	// project Ids are created using the process
-	// "Project##{name-of-project}" -> Sha256 -> Hex
-
-	hashed := sha256.Sum256([]byte("Project##" + name))
-	return hex.EncodeToString(hashed[:])
+// Name returns the name the repository was registered with
+func (fr *ProjectRepository) Name() string {
+	return fr.name
 }
 
 func (fr *ProjectRepository) HistFile(name string) string {
@@ -81,38 +98,90 @@ func (fr *ProjectRepository) HistFile(name string) string {
 }
 
 func (fr *ProjectRepository) Create(name string) (err error) {
+	_, err = fr.create(name)
+	return
+}
+
+// create makes an empty project folder, created is false if the user chose not to overwrite an existing project
+func (fr *ProjectRepository) create(name string) (created bool, err error) {
+	if err = ValidateName(name); err != nil {
+		return
+	}
+
 	folder := fr.Path(name)
 	logrus.Debugf("Creating \"%s\" at %s", name, folder)
 
+	if a, err := fr.Archived(name); err != nil {
+		return false, err
+	} else if a != nil {
+		return false, ErrProjectArchived
+	}
+
 	if _, err := os.Stat(folder); !os.IsNotExist(err) {
 		if fr.interactive && !Confirm("%s already exists, overwrite it?", name) {
-			return nil
+			return false, nil
 		}
 
-		logrus.Debugf("Removing %s", folder)
-		os.RemoveAll(folder)
+		_, err = fr.trash(name, TrashReasonOverwritten)
+		if err != nil {
+			return false, errors.WithMessage(err, "while moving the old project to the trash")
+		}
 	}
 
 	logrus.Debugf("Making directory %s", folder)
-	return os.MkdirAll(folder, projectFolderPerm)
+	err = os.MkdirAll(folder, projectFolderPerm)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	err = fr.WriteMetadata(name, &Metadata{Id: newId(), Created: &now})
+	if err != nil {
+		return true, err
+	}
+
+	_, err = fr.ensureId(name)
+	return true, err
 }
 
 func (fr *ProjectRepository) Delete(name string) (err error) {
 	folder := fr.Path(name)
 	logrus.Debugf("Removing \"%s\" at %s", name, folder)
 
-	if fr.interactive && !Confirm("Are you sure you want to delete %s?", name) {
+	if fr.interactive && !Confirm("Are you sure you want to move %s to the trash?", name) {
 		return nil
 	}
-	return os.RemoveAll(folder)
+
+	if _, err := os.Stat(folder); err == nil {
+		_, err = fr.trash(name, TrashReasonRemoved)
+		if err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := fr.unarchived(name); err != nil {
+		logrus.Debugf("Couldn't forget the archived copy: %v", err)
+	}
+
+	frecency, err := LoadFrecency()
+	if err == nil {
+		frecency.Forget(fr.name, name)
+		err = frecency.Write()
+	}
+
+	if err != nil {
+		logrus.Debugf("Couldn't forget visits: %v", err)
+	}
+	return nil
 }
 
+// Visit goes to a project in a new instance of the user's shell, which replaces this process unless the project
+// has hooks to run when it's left
 func (fr *ProjectRepository) Visit(name string) (err error) {
-	folder := fr.Path(name)
-	if _, err := os.Stat(folder); os.IsNotExist(err) {
-		return ErrNoSuchProject
-	} else if err != nil {
-		return err
+	a, err := fr.Activate(name)
+	if err != nil {
+		return
 	}
 
 	shell := os.Getenv("SHELL")
@@ -128,12 +197,12 @@ func (fr *ProjectRepository) Visit(name string) (err error) {
 		return err
 	}
 
-	env := modEnviron(map[string]string{
-		"PROJ_CURRENT_PROJECT_BASE": folder,
-		"PROJ_CURRENT_PROJECT_NAME": name,
-		"HISTFILE":                  fr.HistFile(name),
-		"fish_history":              fr.Id(name),
-	})
+	folder := fr.Path(name)
+	env := modEnviron(a.Env)
+	err = runHooks(folder, env, os.Stdout, a.OnEnter)
+	if err != nil {
+		return
+	}
 
 	err = os.Chdir(folder)
 	if err != nil {
@@ -141,43 +210,57 @@ func (fr *ProjectRepository) Visit(name string) (err error) {
 	}
 
 	ClearScreen()
-	return syscall.Exec(shell, []string{invokedExe}, env)
+	if len(a.OnExit) == 0 {
+		return syscall.Exec(shell, []string{invokedExe}, env)
+	}
+
+	cmd := exec.Command(shell)
+	cmd.Args = []string{invokedExe}
+	cmd.Env = env
+	cmd.Stderr = os.Stderr
+	cmd.Stdout = os.Stdout
+	cmd.Stdin = os.Stdin
+	if err := cmd.Run(); err != nil {
+		logrus.Debugf("Shell exited with %v", err)
+	}
+	return runHooks(folder, env, os.Stdout, a.OnExit)
 }
 
+// List lists the projects matching a query, see Query for the syntax
 func (fr *ProjectRepository) List(filters ...string) (matches []string, err error) {
-	folder := fr.Path("")
-	finfo, err := ioutil.ReadDir(folder)
+	q, err := ParseQuery(filters...)
 	if err != nil {
 		return
 	}
 
-	compiledFilters := make([]*regexp.Regexp, 0, len(filters))
-	matches = make([]string, 0, len(finfo))
+	projects, err := fr.Query(q)
+	if err != nil {
+		return
+	}
 
-	for _, filter := range filters {
-		re, err := regexp.Compile(filter)
-		if err != nil {
-			return nil, err
-		}
-		compiledFilters = append(compiledFilters, re)
+	matches = make([]string, 0, len(projects))
+	for _, p := range projects {
+		matches = append(matches, p.Name)
 	}
+	return
+}
 
-	for _, f := range finfo {
-		if !f.IsDir() {
-			continue
-		}
+// ListTagged lists projects matching filters, that are tagged with every one of tags
+func (fr *ProjectRepository) ListTagged(tags []string, filters ...string) (matches []string, err error) {
+	projects, err := fr.List(filters...)
+	if err != nil || len(tags) == 0 {
+		return projects, err
+	}
 
-		itemName := f.Name()
-		canWrite := true
-		for _, re := range compiledFilters {
-			if !re.MatchString(itemName) {
-				canWrite = true
-				break
-			}
+	matches = make([]string, 0, len(projects))
+	for _, project := range projects {
+		meta, err := fr.Metadata(project)
+		if err != nil {
+			return nil, errors.WithMessage(err, project)
 		}
 
-		if canWrite {
-			matches = append(matches, itemName)
+		if meta.HasTags(tags...) {
+			matches = append(matches, project)
 		}
 	}
 	return
@@ -185,32 +268,44 @@ func (fr *ProjectRepository) List(filters ...string) (matches []string, err erro
 
 func (fr *ProjectRepository) NonInteractive() *ProjectRepository {
 	return &ProjectRepository{
+		name:        fr.name,
 		baseFolder:  fr.baseFolder,
 		interactive: false,
 	}
 }
 
-func (fr *ProjectRepository) Upload(name string, s StorageService) (err error) {
-	folder := fr.Path(name)
-	remoteFolder := "/" + name
-	if _, err := os.Stat(folder); os.IsNotExist(err) {
-		return ErrNoSuchProject
-	} else if err != nil {
-		return err
-	}
-
+// loadIgnore compiles a project's .gitignore, the result is nil if the project doesn't have one
+func loadIgnore(folder string) (ignore *gitignore.GitIgnore, err error) {
 	ignorePath := path.Join(folder, ".gitignore")
-	var ignore *gitignore.GitIgnore
 	if _, err := os.Stat(ignorePath); !os.IsNotExist(err) {
 		ignore, err = gitignore.CompileIgnoreFile(ignorePath)
 		if err != nil {
-			return errors.WithMessage(err, "while parsing gitignore")
+			return nil, errors.WithMessage(err, "while parsing gitignore")
 		}
 
 		logrus.Debugf("using gitignore file %s", ignorePath)
 	} else {
 		logrus.Debugf("gitignore (%s) not found", ignorePath)
+	}
+	return
+}
 
+func (fr *ProjectRepository) Upload(name string, s StorageService) (err error) {
+	folder := fr.Path(name)
+	if _, err := os.Stat(folder); os.IsNotExist(err) {
+		return ErrNoSuchProject
+	} else if err != nil {
+		return err
+	}
+
+	remoteFolder, err := fr.uploadFolder(name, s)
+	if err != nil {
+		return err
+	}
+
+	ignore, err := loadIgnore(folder)
+	if err != nil {
+		return err
 	}
 
 	deleteList := make([]string, 0, 32)
@@ -268,7 +363,10 @@ func (fr *ProjectRepository) Upload(name string, s StorageService) (err error) {
 
 func (fr *ProjectRepository) Pull(name string, s StorageService) (err error) {
 	folder := fr.Path(name)
-	remoteFolder := "/" + name
+	remoteFolder, err := fr.remoteFolder(name, s)
+	if err != nil {
+		return
+	}
 
 	os.MkdirAll(folder, projectFolderPerm)
 
@@ -293,16 +391,167 @@ func (fr *ProjectRepository) Pull(name string, s StorageService) (err error) {
 		})
 }
 
+func (fr *ProjectRepository) idTag(name string) string {
+	return "proj-id:" + fr.Id(name)
+}
+
+// BackupTags are the tags every backup of a project is labeled with
+func (fr *ProjectRepository) BackupTags(name string) []string {
+	return []string{fr.idTag(name), "proj-name:" + name}
+}
+
 func (fr *ProjectRepository) Backup(bs BackupService, name string, repos ...string) (err error) {
-	return bs.Backup(fr.Path(name), repos...)
+	_, err = fr.ensureId(name)
+	if err != nil {
+		return
+	}
+	return bs.Backup(fr.Path(name), fr.BackupTags(name), repos...)
+}
+
+// Prune removes a project's snapshots that aren't kept by policy, from every repository, snapshots made before
+// the project had a persistent Id are pruned separately
+func (fr *ProjectRepository) Prune(p Pruner, name string, policy RetentionPolicy, repos ...string) (err error) {
+	for _, repo := range repos {
+		for _, id := range fr.Ids(name) {
+			err = p.Prune(repo, []string{"proj-id:" + id}, policy)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return
+}
+
+// snapshotTags are the tags a project's snapshots are found by, every Id it's had, so snapshots taken before it
+// was renamed or moved are found too. A project that doesn't exist has no Id yet, so it's found by name.
+func (fr *ProjectRepository) snapshotTags(name string) (tags []string) {
+	for _, id := range fr.Ids(name) {
+		tags = append(tags, "proj-id:"+id)
+	}
+
+	if _, err := os.Stat(fr.Path(name)); os.IsNotExist(err) {
+		tags = append(tags, "proj-name:"+name)
+	}
+	return
+}
+
+func (fr *ProjectRepository) Snapshots(bs BackupService, name string, repo string) ([]Snapshot, error) {
+	return bs.Snapshots(fr.Path(name), repo, fr.snapshotTags(name))
+}
+
+// BackedUp lists the projects of this repository with snapshots in a backup repository, including projects that
+// no longer exist. A project that was renamed is listed by the name of its latest snapshot.
+func (fr *ProjectRepository) BackedUp(bs BackupService, repo string) (names []string, err error) {
+	snapshots, err := bs.Snapshots("", repo, nil)
+	if err != nil {
+		return
+	}
+
+	// snapshots are oldest first, so later snapshots of a project replace the name of earlier ones
+	latest := make(map[string]string, len(snapshots))
+	for _, snapshot := range snapshots {
+		if len(snapshot.Paths) == 0 {
+			continue
+		}
+
+		key := snapshot.Paths[0]
+		for _, tag := range snapshot.Tags {
+			if strings.HasPrefix(tag, "proj-id:") {
+				key = tag
+				break
+			}
+		}
+
+		if path.Dir(snapshot.Paths[0]) == path.Clean(fr.baseFolder) {
+			latest[key] = path.Base(snapshot.Paths[0])
+		} else {
+			delete(latest, key)
+		}
+	}
+
+	seen := make(map[string]bool, len(latest))
+	for _, name := range latest {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return
+}
+
+// RestoreSnapshot restores a snapshot of a project, if target is empty the project's folder is restored in place
+func (fr *ProjectRepository) RestoreSnapshot(bs BackupService, name, repo, snapshot, target string) (err error) {
+	if target == "" {
+		target = fr.Path(name)
+		if _, err = os.Stat(target); !os.IsNotExist(err) {
+			if fr.interactive && !Confirm("the project %s already exists, are you sure you want to restore from a backup?", name) {
+				return nil
+			}
+		}
+	} else if files, err := ioutil.ReadDir(target); err == nil && len(files) > 0 {
+		// target is replaced by the snapshot, so anything in it is lost
+		if !fr.interactive {
+			return errors.WithMessage(ErrTargetNotEmpty, target)
+		} else if !Confirm("%s isn't empty, are you sure you want to replace everything in it with a backup of %s?", target, name) {
+			return nil
+		}
+	} else if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	snapshots, err := fr.Snapshots(bs, name, repo)
+	if err != nil {
+		return
+	}
+
+	found, err := FindSnapshot(snapshots, snapshot)
+	if err != nil {
+		return
+	}
+
+	if target == fr.Path(name) {
+		return fr.restoreOver(name, func() error {
+			return bs.RestoreSnapshot(repo, found, target)
+		})
+	}
+	return bs.RestoreSnapshot(repo, found, target)
 }
 
 func (fr *ProjectRepository) Restore(bs BackupService, name string, repo string) (err error) {
 	folder := fr.Path(name)
 	if _, err = os.Stat(folder); !os.IsNotExist(err) {
-		if !Confirm("the project %s already exists, are you sure you want to restore from a backup?", name) {
+		if fr.interactive && !Confirm("the project %s already exists, are you sure you want to restore from a backup?", name) {
 			return nil
 		}
 	}
-	return bs.Backup(folder, repo)
+
+	// the tags come from the project's metadata, so they're found before it's moved to the trash
+	tags := fr.snapshotTags(name)
+	return fr.restoreOver(name, func() error {
+		return bs.Restore(folder, repo, tags)
+	})
+}
+
+// restoreOver runs restore, which restores a project from a backup, after moving the project's current files to
+// the trash, they're put back if the restore fails
+func (fr *ProjectRepository) restoreOver(name string, restore func() error) (err error) {
+	if _, err = os.Stat(fr.Path(name)); os.IsNotExist(err) {
+		return restore()
+	} else if err != nil {
+		return
+	}
+
+	trashed, err := fr.trash(name, TrashReasonOverwritten)
+	if err != nil {
+		return errors.WithMessage(err, "while moving the old project to the trash")
+	}
+
+	err = restore()
+	if err != nil {
+		if restoreErr := trashed.Restore(fr, name); restoreErr != nil {
+			logrus.Errorf("Couldn't put %s back, it's been left in the trash as %s: %v", name, trashed.Id, restoreErr)
+		}
+	}
+	return
 }
@@ -0,0 +1,257 @@
+package proj
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ScheduleStatusPath = path.Join(os.Getenv("HOME"), ".proj", "schedule-status.json")
+
+var (
+	ErrInvalidSchedule = errors.New("Invalid schedule, expected 5 fields (minute hour day-of-month month day-of-week) or @hourly/@daily/@weekly/@monthly")
+)
+
+const (
+	// JobActionBackup backs up projects to every backup repository
+	JobActionBackup = "backup"
+
+	// JobActionUpload uploads projects to a remote
+	JobActionUpload = "upload"
+)
+
+// JobActions lists every action a scheduled job can run
+var JobActions = []string{JobActionBackup, JobActionUpload}
+
+// Job is a recurring action run by proj daemon
+type Job struct {
+	// Schedule is a cron expression deciding when the job runs
+	Schedule string `json:"schedule"`
+	Action   string `json:"action"`
+
+	// Project is the project the job runs on, if it's empty the job runs on every project
+	Project string `json:"project,omitempty"`
+
+	// Repo limits the job to one project repository, if it's empty the primary repository is used for a
+	// single project, and every repository is used for all projects
+	Repo string `json:"repo,omitempty"`
+
+	// Remote is where upload jobs upload to
+	Remote string `json:"remote,omitempty"`
+}
+
+// JobStatus records the outcome of a job's runs
+type JobStatus struct {
+	LastRun     time.Time `json:"last-run"`
+	LastSuccess time.Time `json:"last-success,omitempty"`
+	LastError   string    `json:"last-error,omitempty"`
+	Runs        int       `json:"runs"`
+	Failures    int       `json:"failures"`
+}
+
+// ScheduleStatus is the status of every job, keyed by job name
+type ScheduleStatus map[string]*JobStatus
+
+// LoadScheduleStatus reads the status of every job, a missing status file gives an empty status
+func LoadScheduleStatus() (status ScheduleStatus, err error) {
+	status = make(ScheduleStatus)
+	data, err := ioutil.ReadFile(ScheduleStatusPath)
+	if os.IsNotExist(err) {
+		return status, nil
+	} else if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(data, &status)
+	return
+}
+
+// Record updates a job's status after it has run
+func (status ScheduleStatus) Record(job string, ran time.Time, err error) {
+	js, exists := status[job]
+	if !exists {
+		js = &JobStatus{}
+		status[job] = js
+	}
+
+	js.LastRun = ran
+	js.Runs++
+	if err != nil {
+		js.LastError = err.Error()
+		js.Failures++
+	} else {
+		js.LastError = ""
+		js.LastSuccess = ran
+	}
+}
+
+func (status ScheduleStatus) Write() error {
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(path.Dir(ScheduleStatusPath), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(ScheduleStatusPath, data, 0600)
+}
+
+// Schedule is a parsed cron expression, each field is a bitset of the values it matches
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// restrictedDays is true when neither day-of-month nor day-of-week starts with a *, in which case cron runs
+	// on days matching either one, a field like */2 still counts as unrestricted
+	restrictedDays bool
+}
+
+var scheduleMacros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+}
+
+// parseScheduleField parses one field of a cron expression, e.g. "*/15", "1-5", "0,30"
+func parseScheduleField(field string, min, max int) (bits uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return 0, ErrInvalidSchedule
+			}
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			lo, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return 0, ErrInvalidSchedule
+			}
+
+			hi = lo
+			if len(bounds) == 2 {
+				hi, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return 0, ErrInvalidSchedule
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return 0, ErrInvalidSchedule
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return
+}
+
+// ParseSchedule parses a cron expression, e.g. "30 2 * * 1-5" or "@daily"
+func ParseSchedule(expr string) (s *Schedule, err error) {
+	expr = strings.TrimSpace(expr)
+	if macro, exists := scheduleMacros[expr]; exists {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, ErrInvalidSchedule
+	}
+
+	s = &Schedule{}
+	ranges := []struct {
+		bits     *uint64
+		min, max int
+	}{
+		{&s.minute, 0, 59},
+		{&s.hour, 0, 23},
+		{&s.dom, 1, 31},
+		{&s.month, 1, 12},
+		{&s.dow, 0, 7},
+	}
+
+	for i, r := range ranges {
+		*r.bits, err = parseScheduleField(fields[i], r.min, r.max)
+		if err != nil {
+			return nil, errors.WithMessage(err, fields[i])
+		}
+	}
+
+	// both 0 and 7 are sunday
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+
+	s.restrictedDays = !strings.HasPrefix(fields[2], "*") && !strings.HasPrefix(fields[4], "*")
+	return
+}
+
+// Matches is true if the schedule runs during t's minute
+func (s *Schedule) Matches(t time.Time) bool {
+	if s.minute&(1<<uint(t.Minute())) == 0 ||
+		s.hour&(1<<uint(t.Hour())) == 0 ||
+		s.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	return s.dayMatches(t)
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.restrictedDays {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// Next finds the next minute after t the schedule runs, or the zero time if it doesn't run in the next 5 years
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(5, 0, 0)
+
+	for t.Before(end) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+	return time.Time{}
+}
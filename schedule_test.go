@@ -0,0 +1,117 @@
+package proj
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleMatches(t *testing.T) {
+	at := func(s string) time.Time {
+		parsed, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	// 2020-03-02 is a monday
+	for _, test := range []struct {
+		expr    string
+		time    string
+		matches bool
+	}{
+		{"@daily", "2020-03-02 00:00", true},
+		{"@daily", "2020-03-02 00:01", false},
+		{"@hourly", "2020-03-02 13:00", true},
+		{"@hourly", "2020-03-02 13:30", false},
+		{"@weekly", "2020-03-01 00:00", true},
+		{"@weekly", "2020-03-02 00:00", false},
+		{"@monthly", "2020-03-01 00:00", true},
+		{"@yearly", "2020-01-01 00:00", true},
+		{"@yearly", "2020-03-01 00:00", false},
+		{"30 2 * * 1-5", "2020-03-02 02:30", true},
+		{"30 2 * * 1-5", "2020-03-01 02:30", false},
+		{"*/15 * * * *", "2020-03-02 10:45", true},
+		{"*/15 * * * *", "2020-03-02 10:50", false},
+		{"5/20 * * * *", "2020-03-02 10:45", true},
+		{"5/20 * * * *", "2020-03-02 10:40", false},
+		{"0 8-18/2 * * *", "2020-03-02 12:00", true},
+		{"0 8-18/2 * * *", "2020-03-02 13:00", false},
+		{"0 8-18/2 * * *", "2020-03-02 20:00", false},
+		{"0,30 9,17 * * *", "2020-03-02 17:30", true},
+		{"0,30 9,17 * * *", "2020-03-02 12:30", false},
+		{"0 0 * * 7", "2020-03-01 00:00", true},
+		{"0 0 * 1,6 *", "2020-06-10 00:00", true},
+		{"0 0 * 1,6 *", "2020-03-10 00:00", false},
+
+		// when both day fields are restricted, either one matching is enough
+		{"0 0 15 * 1", "2020-03-02 00:00", true},
+		{"0 0 15 * 1", "2020-03-15 00:00", true},
+		{"0 0 15 * 1", "2020-03-03 00:00", false},
+
+		// a day field starting with * doesn't restrict the days, even with a step, so both have to match
+		{"0 0 */2 * 1", "2020-03-02 00:00", false},
+		{"0 0 */2 * 1", "2020-03-09 00:00", true},
+		{"0 0 */2 * 1", "2020-03-03 00:00", false},
+		{"0 0 1 * */2", "2020-03-01 00:00", true},
+		{"0 0 1 * */2", "2020-04-01 00:00", false},
+		{"0 0 13 * *", "2020-03-13 00:00", true},
+		{"0 0 13 * *", "2020-03-14 00:00", false},
+	} {
+		s, err := ParseSchedule(test.expr)
+		if err != nil {
+			t.Errorf("%q: %v", test.expr, err)
+			continue
+		}
+
+		if matches := s.Matches(at(test.time)); matches != test.matches {
+			t.Errorf("%q at %s: expected %t, got %t", test.expr, test.time, test.matches, matches)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	from := time.Date(2020, 3, 2, 10, 7, 30, 0, time.Local)
+	for _, test := range []struct {
+		expr string
+		next time.Time
+	}{
+		{"@hourly", time.Date(2020, 3, 2, 11, 0, 0, 0, time.Local)},
+		{"@daily", time.Date(2020, 3, 3, 0, 0, 0, 0, time.Local)},
+		{"*/15 * * * *", time.Date(2020, 3, 2, 10, 15, 0, 0, time.Local)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.Local)},
+		{"0 0 31 2 *", time.Time{}},
+	} {
+		s, err := ParseSchedule(test.expr)
+		if err != nil {
+			t.Errorf("%q: %v", test.expr, err)
+			continue
+		}
+
+		if next := s.Next(from); !next.Equal(test.next) {
+			t.Errorf("%q: expected %s, got %s", test.expr, test.next, next)
+		}
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"@sometimes",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"1-a * * * *",
+	} {
+		if _, err := ParseSchedule(expr); err == nil {
+			t.Errorf("%q: expected an error", expr)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package proj
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrUnknownShell = errors.New("Unknown shell, expected bash, zsh or fish")
+	ErrNotVisiting  = errors.New("Not visiting a project")
+)
+
+// Shell is a shell proj can integrate with, so visiting a project changes the current shell instead of starting
+// a new one
+type Shell string
+
+const (
+	ShellBash Shell = "bash"
+	ShellZsh  Shell = "zsh"
+	ShellFish Shell = "fish"
+)
+
+// Shells lists every shell proj can integrate with
+var Shells = []Shell{ShellBash, ShellZsh, ShellFish}
+
+// ShellEnvVar is set by the shell integration when it runs proj, it names the shell that proj should write
+// scripts for
+const ShellEnvVar = "PROJ_SHELL"
+
+// ShellScriptMarker is the first line of every script proj writes for the shell integration, the integration only
+// runs output that starts with it, so help and usage messages are shown instead of run
+const ShellScriptMarker = "# proj-script"
+
+const (
+	// shellVarsVar lists the variables set by visiting the current project, so leaving can undo them
+	shellVarsVar = "PROJ_SHELL_VARS"
+
+	// shellSavedPrefix prefixes the variables holding the values that visiting a project replaced
+	shellSavedPrefix = "PROJ_SAVED_"
+
+	// shellPreviousDirVar is the folder the shell was in before it visited a project
+	shellPreviousDirVar = "PROJ_PREVIOUS_DIR"
+
+	// shellOnExitVar holds the hooks to run when leaving the current project, as a JSON list
+	shellOnExitVar = "PROJ_ON_EXIT"
+)
+
+// ParseShell finds a shell by name, or by the path to its executable
+func ParseShell(name string) (Shell, error) {
+	name = name[strings.LastIndexByte(name, '/')+1:]
+	for _, sh := range Shells {
+		if string(sh) == name {
+			return sh, nil
+		}
+	}
+	return "", ErrUnknownShell
+}
+
+// Init is the shell code that wraps proj in a function, so proj visit and proj leave can change the shell
+// they're run from, it's meant to be eval'd in the shell's rc file. Only output starting with ShellScriptMarker
+// is run, anything else, and any help, is printed. HISTFILE usually isn't exported, so the wrapper passes it
+// along for proj to restore when leaving a project
+func (sh Shell) Init() string {
+	if sh == ShellFish {
+		return `function proj --wraps proj --description 'Project manager'
+	switch "$argv[1]"
+		case visit v jump j pick leave
+			if contains -- -h $argv; or contains -- --help $argv
+				command proj $argv
+				return
+			end
+
+			set -l script (env ` + ShellEnvVar + `=fish proj $argv)
+			or return
+			if test "$script[1]" = '` + ShellScriptMarker + `'
+				printf '%s\n' $script | source
+			else if test (count $script) -gt 0
+				printf '%s\n' $script
+			end
+		case '*'
+			command proj $argv
+	end
+end
+`
+	}
+
+	return `proj() {
+	case "$1" in
+	visit|v|jump|j|pick|leave)
+		local arg script
+		for arg in "$@"; do
+			case "$arg" in
+			-h|--help)
+				command proj "$@"
+				return
+				;;
+			esac
+		done
+
+		script="$(` + ShellEnvVar + `=` + string(sh) + ` HISTFILE="$HISTFILE" command proj "$@")" || return
+		case "$script" in
+		'` + ShellScriptMarker + `'*)
+			eval "$script"
+			;;
+		?*)
+			printf '%s\n' "$script"
+			;;
+		esac
+		;;
+	*)
+		command proj "$@"
+		;;
+	esac
+}
+`
+}
+
+func (sh Shell) quote(s string) string {
+	if sh == ShellFish {
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func (sh Shell) set(name, value string) string {
+	if sh == ShellFish {
+		return "set -gx " + name + " " + sh.quote(value) + "\n"
+	}
+	return "export " + name + "=" + sh.quote(value) + "\n"
+}
+
+func (sh Shell) unset(name string) string {
+	if sh == ShellFish {
+		return "set -e " + name + "\n"
+	}
+	return "unset " + name + "\n"
+}
+
+func (sh Shell) cd(folder string) string {
+	return "cd " + sh.quote(folder) + "\n"
+}
+
+// Echo is a script that prints text, for commands run by the shell integration that print instead of changing
+// the shell
+func (sh Shell) Echo(text string) string {
+	return "printf '%s\\n' " + sh.quote(text) + "\n"
+}
+
+// shellEnv is the environment a shell will have once the script so far has run
+type shellEnv map[string]string
+
+func currentShellEnv() shellEnv {
+	env := make(shellEnv)
+	for _, v := range os.Environ() {
+		if i := strings.IndexByte(v, '='); i > 0 {
+			env[v[:i]] = v[i+1:]
+		}
+	}
+	return env
+}
+
+func (sh Shell) setEnv(script *strings.Builder, env shellEnv, name, value string) {
+	env[name] = value
+	script.WriteString(sh.set(name, value))
+}
+
+func (sh Shell) unsetEnv(script *strings.Builder, env shellEnv, name string) {
+	delete(env, name)
+	script.WriteString(sh.unset(name))
+}
+
+func (env shellEnv) environ() []string {
+	environ := make([]string, 0, len(env))
+	for k, v := range env {
+		environ = append(environ, k+"="+v)
+	}
+	return environ
+}
+
+// leave runs the current project's exit hooks, and undoes the variables set by visiting it
+func (sh Shell) leave(script *strings.Builder, env shellEnv) {
+	onExit := []string{}
+	if err := json.Unmarshal([]byte(env[shellOnExitVar]), &onExit); err == nil {
+		// hooks write to stderr, the shell runs whatever's written to stdout
+		if err := runHooks(env["PROJ_CURRENT_PROJECT_BASE"], env.environ(), os.Stderr, onExit); err != nil {
+			logrus.Warn(err)
+		}
+	}
+
+	if sh == ShellBash {
+		// save this project's history before switching files
+		script.WriteString("history -a\n")
+	}
+
+	for _, name := range strings.Fields(env[shellVarsVar]) {
+		if saved, exists := env[shellSavedPrefix+name]; exists {
+			sh.setEnv(script, env, name, saved)
+			sh.unsetEnv(script, env, shellSavedPrefix+name)
+		} else {
+			sh.unsetEnv(script, env, name)
+		}
+	}
+	sh.unsetEnv(script, env, shellVarsVar)
+
+	switch sh {
+	case ShellBash:
+		script.WriteString("history -c\nhistory -r\n")
+	case ShellZsh:
+		script.WriteString("fc -P\n")
+	}
+}
+
+// Enter runs a project's enter hooks, and gives a script that changes the shell's folder to the project's and
+// sets the project's variables, if the shell is already visiting a project it leaves that project first
+func (sh Shell) Enter(folder string, a *Activation) (script string, err error) {
+	env := currentShellEnv()
+	b := &strings.Builder{}
+
+	previousDir, visiting := env[shellPreviousDirVar]
+	if _, inProject := env[shellVarsVar]; inProject && visiting {
+		sh.leave(b, env)
+	} else {
+		previousDir, err = os.Getwd()
+		if err != nil {
+			return
+		}
+
+		if sh == ShellBash {
+			b.WriteString("history -a\n")
+		}
+	}
+
+	vars := make(map[string]string, len(a.Env)+1)
+	for k, v := range a.Env {
+		vars[k] = v
+	}
+
+	if len(a.OnExit) > 0 {
+		onExit, err := json.Marshal(a.OnExit)
+		if err != nil {
+			return "", err
+		}
+		vars[shellOnExitVar] = string(onExit)
+	}
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		// zsh keeps a stack of history files instead, and fish names its history with fish_history
+		if (name == "HISTFILE" && sh != ShellBash) || (name == "fish_history" && sh != ShellFish) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if old, exists := env[name]; exists {
+			sh.setEnv(b, env, shellSavedPrefix+name, old)
+		}
+		sh.setEnv(b, env, name, vars[name])
+	}
+	sh.setEnv(b, env, shellVarsVar, strings.Join(names, " "))
+	sh.setEnv(b, env, shellPreviousDirVar, previousDir)
+
+	switch sh {
+	case ShellBash:
+		b.WriteString("history -c\nhistory -r\n")
+	case ShellZsh:
+		if histfile, exists := vars["HISTFILE"]; exists {
+			b.WriteString("fc -p " + sh.quote(histfile) + "\n")
+		}
+	}
+
+	err = runHooks(folder, env.environ(), os.Stderr, a.OnEnter)
+	if err != nil {
+		return
+	}
+
+	b.WriteString(sh.cd(folder))
+	return b.String(), nil
+}
+
+// Leave is a script that restores the shell to how it was before it visited a project
+func (sh Shell) Leave() (script string, err error) {
+	env := currentShellEnv()
+	previousDir, visiting := env[shellPreviousDirVar]
+	if _, inProject := env[shellVarsVar]; !inProject || !visiting {
+		return "", ErrNotVisiting
+	}
+
+	b := &strings.Builder{}
+	sh.leave(b, env)
+	sh.unsetEnv(b, env, shellPreviousDirVar)
+	b.WriteString(sh.cd(previousDir))
+	return b.String(), nil
+}
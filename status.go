@@ -0,0 +1,98 @@
+package proj
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+const (
+	// SyncStatusSynced means every file on the remote matches the project
+	SyncStatusSynced = "synced"
+
+	// SyncStatusModified means the project and the remote have different files
+	SyncStatusModified = "modified"
+
+	// SyncStatusNotUploaded means the project has never been uploaded to the remote
+	SyncStatusNotUploaded = "not-uploaded"
+)
+
+// GitStatus finds the branch a project has checked out, and whether it has uncommitted changes, branch is
+// empty if the project isn't a git repository or git isn't installed
+func GitStatus(folder string) (branch string, dirty bool, err error) {
+	if _, err := os.Stat(path.Join(folder, ".git")); os.IsNotExist(err) {
+		return "", false, nil
+	}
+
+	git, err := lookupExecutable("git", ErrGitNotFound)
+	if err == ErrGitNotFound {
+		return "", false, nil
+	} else if err != nil {
+		return
+	}
+
+	out, err := exec.Command(git, "-C", folder, "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err != nil {
+		// a repository without any commits doesn't have a HEAD yet
+		out, err = exec.Command(git, "-C", folder, "symbolic-ref", "--short", "HEAD").Output()
+		if err != nil {
+			return "", false, nil
+		}
+	}
+	branch = strings.TrimSpace(string(out))
+
+	out, err = exec.Command(git, "-C", folder, "status", "--porcelain").Output()
+	if err != nil {
+		return
+	}
+	return branch, len(out) > 0, nil
+}
+
+// SyncStatus compares a project to its copy on a StorageService, ignoring files the project's .gitignore
+// would keep from being uploaded
+func (fr *ProjectRepository) SyncStatus(name string, s StorageService) (status string, err error) {
+	folder := fr.Path(name)
+	if _, err := os.Stat(folder); os.IsNotExist(err) {
+		return "", ErrNoSuchProject
+	} else if err != nil {
+		return "", err
+	}
+
+	ignore, err := loadIgnore(folder)
+	if err != nil {
+		return
+	}
+
+	remoteFolder, err := fr.remoteFolder(name, s)
+	if err != nil {
+		return
+	}
+
+	uploaded, changed := false, false
+	err = s.WalkDiffs(folder, remoteFolder,
+		func(file string, info os.FileInfo) bool {
+			return ignore != nil && ignore.MatchesPath(file)
+		},
+		func(file string, diff DiffResult) error {
+			switch diff {
+			case DiffResultMatch:
+				uploaded = true
+			case DiffResultMismatch, DiffResultOnlyExistsRemote:
+				uploaded, changed = true, true
+			case DiffResultOnlyExistsLocal:
+				changed = true
+			}
+			return nil
+		})
+
+	switch {
+	case err != nil:
+		return "", err
+	case !uploaded:
+		return SyncStatusNotUploaded, nil
+	case changed:
+		return SyncStatusModified, nil
+	}
+	return SyncStatusSynced, nil
+}
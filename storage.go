@@ -1,6 +1,9 @@
 package proj
 
-import "os"
+import (
+	"os"
+	"path"
+)
 
 // DiffResult explains broadly explains the difference between the remote and local versions of a file
 type DiffResult uint8
@@ -38,4 +41,45 @@ type StorageService interface {
 
 	// Delete removes a file from the storage service
 	Delete(remote string) error
+
+	// Move moves a remote file or folder from "from" to "to", moving something that doesn't exist does nothing
+	Move(from, to string) error
+
+	// List lists every file under a remote folder, relative to it, without changing anything. A folder that
+	// doesn't exist has no files.
+	List(remote string) ([]string, error)
+}
+
+type subfolderStorage struct {
+	s      StorageService
+	folder string
+}
+
+// Subfolder gives a StorageService where every remote path is inside folder
+func Subfolder(s StorageService, folder string) StorageService {
+	return &subfolderStorage{s: s, folder: folder}
+}
+
+func (sf *subfolderStorage) WalkDiffs(local, remote string, skip SkipCallback, callback WalkDiffsCallback) error {
+	return sf.s.WalkDiffs(local, path.Join(sf.folder, remote), skip, callback)
+}
+
+func (sf *subfolderStorage) Upload(local, remote string) error {
+	return sf.s.Upload(local, path.Join(sf.folder, remote))
+}
+
+func (sf *subfolderStorage) Download(local, remote string) error {
+	return sf.s.Download(local, path.Join(sf.folder, remote))
+}
+
+func (sf *subfolderStorage) Delete(remote string) error {
+	return sf.s.Delete(path.Join(sf.folder, remote))
+}
+
+func (sf *subfolderStorage) Move(from, to string) error {
+	return sf.s.Move(path.Join(sf.folder, from), path.Join(sf.folder, to))
+}
+
+func (sf *subfolderStorage) List(remote string) ([]string, error) {
+	return sf.s.List(path.Join(sf.folder, remote))
 }
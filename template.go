@@ -0,0 +1,201 @@
+package proj
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var ErrGitNotFound = errors.New("Git executable not found")
+
+// Template is a folder new projects are copied from, template files and file names can use the variables
+//
+//	{{proj.name}}   the project's name
+//	{{proj.author}} the author configured with proj template author
+//	{{proj.date}}   today's date, e.g. 2006-01-02
+//	{{proj.year}}   the current year
+type Template struct {
+	// Source is a local folder, or a git URL that's cloned every time a project is created
+	Source string `json:"source"`
+
+	// Hooks are shell commands run inside the new project once it's been created (e.g. "git init")
+	Hooks []string `json:"hooks,omitempty"`
+}
+
+// TemplateVars are the values substituted into a template
+type TemplateVars struct {
+	Name   string
+	Author string
+	Date   time.Time
+}
+
+func (tv TemplateVars) replacer() *strings.Replacer {
+	return tv.quotedReplacer(func(s string) string { return s })
+}
+
+// quotedReplacer substitutes the variables quoted by quote, e.g. so they can't inject commands into a hook
+func (tv TemplateVars) quotedReplacer(quote func(string) string) *strings.Replacer {
+	return strings.NewReplacer(
+		"{{proj.name}}", quote(tv.Name),
+		"{{proj.author}}", quote(tv.Author),
+		"{{proj.date}}", quote(tv.Date.Format("2006-01-02")),
+		"{{proj.year}}", quote(tv.Date.Format("2006")),
+	)
+}
+
+// IsGitURL is true if a template source should be cloned instead of copied
+func IsGitURL(source string) bool {
+	for _, prefix := range []string{"git@", "git://", "ssh://", "http://", "https://"} {
+		if strings.HasPrefix(source, prefix) {
+			return true
+		}
+	}
+	return strings.HasSuffix(source, ".git")
+}
+
+// isBinary guesses whether a file is binary the same way git does, by looking for a NUL byte near the start
+func isBinary(data []byte) bool {
+	if len(data) > 8000 {
+		data = data[:8000]
+	}
+	return bytes.IndexByte(data, 0) != -1
+}
+
+// fetch gives a local folder with the template's files, cleanup removes anything that had to be downloaded
+func (t *Template) fetch() (folder string, cleanup func(), err error) {
+	cleanup = func() {}
+	if !IsGitURL(t.Source) {
+		return t.Source, cleanup, nil
+	}
+
+	git, err := lookupExecutable("git", ErrGitNotFound)
+	if err != nil {
+		return
+	}
+
+	tmpdir, err := ioutil.TempDir("", "proj-template")
+	if err != nil {
+		return
+	}
+	cleanup = func() { os.RemoveAll(tmpdir) }
+
+	cmd := exec.Command(git, "clone", "--depth", "1", "--quiet", t.Source, tmpdir)
+	cmd.Stderr = os.Stderr
+	cmd.Stdout = os.Stdout
+	cmd.Stdin = os.Stdin
+	err = cmd.Run()
+	if err != nil {
+		cleanup()
+		return "", func() {}, errors.WithMessage(err, "while cloning "+t.Source)
+	}
+	return tmpdir, cleanup, nil
+}
+
+// Render copies the template into dest, substituting variables into file names and text files
+func (t *Template) Render(dest string, vars TemplateVars) (err error) {
+	src, cleanup, err := t.fetch()
+	if err != nil {
+		return
+	}
+	defer cleanup()
+
+	r := vars.replacer()
+	return filepath.Walk(src, func(file string, info os.FileInfo, walkErr error) (err error) {
+		if walkErr != nil {
+			return walkErr
+		}
+
+		strippedFile, err := filepath.Rel(src, file)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		target := filepath.Join(dest, r.Replace(strippedFile))
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(file)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(r.Replace(link), target)
+		case !info.Mode().IsRegular():
+			logrus.Debugf("Skipping special file %q", strippedFile)
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		if !isBinary(data) {
+			data = []byte(r.Replace(string(data)))
+		}
+
+		logrus.Debugf("(TEMPLATE) %q -> %q", strippedFile, target)
+		return ioutil.WriteFile(target, data, info.Mode().Perm())
+	})
+}
+
+// RunHooks runs the template's hooks in a project's folder, the variables are substituted as quoted shell words,
+// and are also in the environment as PROJ_CURRENT_PROJECT_NAME and PROJ_TEMPLATE_AUTHOR
+func (t *Template) RunHooks(folder string, vars TemplateVars) (err error) {
+	env := modEnviron(map[string]string{
+		"PROJ_CURRENT_PROJECT_BASE": folder,
+		"PROJ_CURRENT_PROJECT_NAME": vars.Name,
+		"PROJ_TEMPLATE_AUTHOR":      vars.Author,
+	})
+
+	r := vars.quotedReplacer(ShellBash.quote)
+	hooks := make([]string, 0, len(t.Hooks))
+	for _, hook := range t.Hooks {
+		logrus.Infof("Running hook %q", hook)
+		hooks = append(hooks, r.Replace(hook))
+	}
+	return runHooks(folder, env, os.Stdout, hooks)
+}
+
+// CreateFromTemplate creates a project, fills it in from a template, then runs the template's hooks
+func (fr *ProjectRepository) CreateFromTemplate(name string, t *Template, author string) (err error) {
+	created, err := fr.create(name)
+	if err != nil || !created {
+		return
+	}
+
+	vars := TemplateVars{
+		Name:   name,
+		Author: author,
+		Date:   time.Now(),
+	}
+
+	folder := fr.Path(name)
+	err = t.Render(folder, vars)
+	if err != nil {
+		return errors.WithMessage(err, "while copying template")
+	}
+
+	return t.RunHooks(folder, vars)
+}
+
+// DefaultAuthor guesses the author of new projects from git's config, falling back to the user's login name
+func DefaultAuthor() string {
+	if out, err := exec.Command("git", "config", "user.name").Output(); err == nil {
+		if author := strings.TrimSpace(string(out)); author != "" {
+			return author
+		}
+	}
+	return os.Getenv("USER")
+}
@@ -0,0 +1,190 @@
+package proj
+
+import (
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var ErrTmuxNotFound = errors.New("Tmux executable not found")
+
+// TmuxWindow is a window created in a project's tmux session
+type TmuxWindow struct {
+	Name string `json:"name,omitempty"`
+
+	// Layout is one of tmux's layouts, e.g. even-horizontal, main-vertical or tiled
+	Layout string `json:"layout,omitempty"`
+
+	// Panes are the commands typed into each of the window's panes, an empty command leaves the pane at a shell
+	Panes []string `json:"panes,omitempty"`
+}
+
+// TmuxSession is the name of a project's tmux session
+func (fr *ProjectRepository) TmuxSession(name string) string {
+	return tmuxSessionName(name, fr.Id(name))
+}
+
+// tmuxSessionName names a project's tmux session after it and its Id, tmux doesn't allow . or : in session names
+func tmuxSessionName(name, id string) string {
+	session := name + "-" + id[:8]
+	return strings.NewReplacer(".", "_", ":", "_").Replace(session)
+}
+
+func tmuxCommand(tmux string, args ...string) *exec.Cmd {
+	logrus.Debugf("(TMUX) %s", strings.Join(args, " "))
+	cmd := exec.Command(tmux, args...)
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+func hasTmuxSession(tmux, session string) bool {
+	cmd := tmuxCommand(tmux, "has-session", "-t", "="+session)
+	cmd.Stderr = nil
+	return cmd.Run() == nil
+}
+
+// createTmuxSession starts a detached session for a project, with the windows from its metadata
+func createTmuxSession(tmux, session, folder string, env map[string]string, windows []TmuxWindow) (err error) {
+	if len(windows) == 0 {
+		windows = []TmuxWindow{{}}
+	}
+
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := []string{"new-session", "-d", "-s", session, "-c", folder}
+	for _, name := range names {
+		args = append(args, "-e", name+"="+env[name])
+	}
+
+	for i, window := range windows {
+		if i == 0 {
+			if window.Name != "" {
+				args = append(args, "-n", window.Name)
+			}
+			err = tmuxCommand(tmux, args...).Run()
+		} else {
+			windowArgs := []string{"new-window", "-t", session, "-c", folder}
+			if window.Name != "" {
+				windowArgs = append(windowArgs, "-n", window.Name)
+			}
+			err = tmuxCommand(tmux, windowArgs...).Run()
+		}
+
+		if err != nil {
+			return errors.WithMessage(err, "while creating the tmux session")
+		}
+
+		// the new window is the session's current window, so it's the target of everything below
+		for pane, command := range window.Panes {
+			if pane > 0 {
+				err = tmuxCommand(tmux, "split-window", "-t", session, "-c", folder).Run()
+				if err != nil {
+					return errors.WithMessage(err, "while splitting a tmux window")
+				}
+			}
+
+			if command != "" {
+				err = tmuxCommand(tmux, "send-keys", "-t", session, command, "Enter").Run()
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if window.Layout != "" {
+			err = tmuxCommand(tmux, "select-layout", "-t", session, window.Layout).Run()
+			if err != nil {
+				return errors.WithMessage(err, "while setting the tmux layout")
+			}
+		}
+	}
+
+	if len(windows) > 1 {
+		return tmuxCommand(tmux, "select-window", "-t", session+":^").Run()
+	}
+	return
+}
+
+// VisitTmux visits a project in its own tmux session, the session is created the first time, and attached to
+// (or switched to, from inside tmux) after that. The project's exit hooks run once the session is gone.
+func (fr *ProjectRepository) VisitTmux(name string) (err error) {
+	tmux, err := lookupExecutable("tmux", ErrTmuxNotFound)
+	if err != nil {
+		return
+	}
+
+	a, err := fr.Activate(name)
+	if err != nil {
+		return
+	}
+
+	folder := fr.Path(name)
+	session := fr.TmuxSession(name)
+	if !hasTmuxSession(tmux, session) {
+		err = runHooks(folder, modEnviron(a.Env), os.Stderr, a.OnEnter)
+		if err != nil {
+			return
+		}
+
+		meta, err := fr.Metadata(name)
+		if err != nil {
+			return err
+		}
+
+		windows := meta.TmuxWindows
+		if a.Untrusted {
+			// the panes' commands are code, just like hooks
+			windows = nil
+		}
+
+		err = createTmuxSession(tmux, session, folder, a.Env, windows)
+		if err != nil {
+			return err
+		}
+	}
+
+	attach := "attach-session"
+	if os.Getenv("TMUX") != "" {
+		attach = "switch-client"
+	}
+
+	// tmux opens the terminal connected to stdin, so this works even when stdout is captured by the shell integration
+	cmd := tmuxCommand(tmux, attach, "-t", "="+session)
+	cmd.Stdin = os.Stdin
+
+	err = cmd.Run()
+	if err != nil {
+		return errors.WithMessage(err, "while attaching to the tmux session")
+	}
+
+	if len(a.OnExit) > 0 && !hasTmuxSession(tmux, session) {
+		return runHooks(folder, modEnviron(a.Env), os.Stderr, a.OnExit)
+	}
+	return
+}
+
+// renameTmux renames a project's tmux session, if it has one, after the project's been renamed
+func renameTmux(session, newSession string) error {
+	tmux, err := lookupExecutable("tmux", ErrTmuxNotFound)
+	if err != nil || session == newSession || !hasTmuxSession(tmux, session) {
+		return nil
+	}
+	return tmuxCommand(tmux, "rename-session", "-t", "="+session, newSession).Run()
+}
+
+// KillTmux ends a project's tmux session
+func (fr *ProjectRepository) KillTmux(name string) (err error) {
+	tmux, err := lookupExecutable("tmux", ErrTmuxNotFound)
+	if err != nil {
+		return
+	}
+	return tmuxCommand(tmux, "kill-session", "-t", "="+fr.TmuxSession(name)).Run()
+}
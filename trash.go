@@ -0,0 +1,203 @@
+package proj
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var TrashPath = path.Join(os.Getenv("HOME"), ".proj", "trash")
+
+// TrashExpiry is how long trashed projects are kept before they're deleted for good, zero keeps them until the
+// trash is emptied
+var TrashExpiry = 30 * 24 * time.Hour
+
+var ErrNoSuchTrashed = errors.New("No such project in the trash")
+
+const (
+	// TrashReasonRemoved is the reason given for projects trashed by proj remove
+	TrashReasonRemoved = "removed"
+
+	// TrashReasonOverwritten is the reason given for projects trashed because a new project replaced them
+	TrashReasonOverwritten = "overwritten"
+)
+
+// trashRecordFile is kept next to each trashed project, describing where it came from
+const trashRecordFile = "trash.json"
+
+// TrashedProject is a project that was removed or overwritten, and can still be restored
+type TrashedProject struct {
+	// Id names the trashed project's folder in the trash
+	Id string `json:"-"`
+
+	Repo    string    `json:"repo"`
+	Name    string    `json:"name"`
+	Folder  string    `json:"folder"`
+	Trashed time.Time `json:"trashed"`
+	Reason  string    `json:"reason"`
+}
+
+func (t *TrashedProject) entry() string {
+	return path.Join(TrashPath, t.Id)
+}
+
+// Path is where the trashed project's files are kept
+func (t *TrashedProject) Path() string {
+	return path.Join(t.entry(), "project")
+}
+
+// Remove deletes the trashed project for good
+func (t *TrashedProject) Remove() error {
+	logrus.Debugf("Removing %s from the trash", t.Name)
+	return os.RemoveAll(t.entry())
+}
+
+// Restore moves a trashed project back into a repository, as name
+func (t *TrashedProject) Restore(to *ProjectRepository, name string) (err error) {
+	if err = ValidateName(name); err != nil {
+		return
+	}
+
+	if _, err := os.Stat(to.Path(name)); err == nil {
+		return ErrProjectExists
+	}
+
+	if a, err := to.Archived(name); err != nil {
+		return err
+	} else if a != nil {
+		return ErrProjectArchived
+	}
+
+	err = moveFolder(t.Path(), to.Path(name))
+	if err != nil {
+		return
+	}
+	return os.RemoveAll(t.entry())
+}
+
+// trash moves a project into the trash, then deletes anything that's been in the trash for longer than
+// TrashExpiry
+func (fr *ProjectRepository) trash(name, reason string) (t *TrashedProject, err error) {
+	t = &TrashedProject{
+		Id:      newId(),
+		Repo:    fr.name,
+		Name:    name,
+		Folder:  fr.Path(name),
+		Trashed: time.Now(),
+		Reason:  reason,
+	}
+
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return
+	}
+
+	err = os.MkdirAll(t.entry(), 0700)
+	if err != nil {
+		return
+	}
+
+	err = ioutil.WriteFile(path.Join(t.entry(), trashRecordFile), data, 0600)
+	if err == nil {
+		logrus.Debugf("Moving %s to the trash", t.Folder)
+		err = moveFolder(t.Folder, t.Path())
+	}
+
+	if err != nil {
+		os.RemoveAll(t.entry())
+		return nil, err
+	}
+
+	if err := ExpireTrash(); err != nil {
+		logrus.Warnf("Couldn't empty old projects from the trash: %v", err)
+	}
+	return
+}
+
+// ListTrash lists every trashed project, most recently trashed first
+func ListTrash() (trashed []*TrashedProject, err error) {
+	finfo, err := ioutil.ReadDir(TrashPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return
+	}
+
+	for _, f := range finfo {
+		t := &TrashedProject{Id: f.Name()}
+		data, err := ioutil.ReadFile(path.Join(t.entry(), trashRecordFile))
+		if err == nil {
+			err = json.Unmarshal(data, t)
+		}
+
+		if err != nil {
+			logrus.Debugf("Skipping %s in the trash: %v", f.Name(), err)
+			continue
+		}
+		trashed = append(trashed, t)
+	}
+
+	sort.Slice(trashed, func(i, j int) bool {
+		return trashed[i].Trashed.After(trashed[j].Trashed)
+	})
+	return
+}
+
+// FindTrashed finds a trashed project by the start of its Id, or by its name, the most recently trashed project
+// with that name is found
+func FindTrashed(nameOrId string) (*TrashedProject, error) {
+	trashed, err := ListTrash()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range trashed {
+		if len(nameOrId) >= 4 && len(nameOrId) <= len(t.Id) && t.Id[:len(nameOrId)] == nameOrId {
+			return t, nil
+		}
+	}
+
+	for _, t := range trashed {
+		if t.Name == nameOrId {
+			return t, nil
+		}
+	}
+	return nil, ErrNoSuchTrashed
+}
+
+// EmptyTrash deletes every project trashed before a time for good
+func EmptyTrash(before time.Time) (removed int, err error) {
+	trashed, err := ListTrash()
+	if err != nil {
+		return
+	}
+
+	for _, t := range trashed {
+		if !t.Trashed.Before(before) {
+			continue
+		}
+
+		err = t.Remove()
+		if err != nil {
+			return
+		}
+		removed++
+	}
+	return
+}
+
+// ExpireTrash deletes every project that's been in the trash for longer than TrashExpiry
+func ExpireTrash() error {
+	if TrashExpiry <= 0 {
+		return nil
+	}
+
+	_, err := EmptyTrash(time.Now().Add(-TrashExpiry))
+	return err
+}
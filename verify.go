@@ -0,0 +1,145 @@
+package proj
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// VerifyResult is the outcome of comparing a project to its latest backup
+type VerifyResult struct {
+	Snapshot Snapshot
+
+	// Matched is the number of files that are identical in the project and the backup
+	Matched int
+
+	// Missing files are in the project, but not in the backup
+	Missing []string
+
+	// Extra files are in the backup, but not in the project, usually because they were deleted after the
+	// snapshot was taken
+	Extra []string
+
+	// Mismatched files have different content in the project and the backup
+	Mismatched []string
+
+	// Changed files were modified after the snapshot was taken, so they're expected to differ
+	Changed []string
+}
+
+// OK is true if the backup has every file the project had when the snapshot was taken
+func (vr *VerifyResult) OK() bool {
+	return len(vr.Missing) == 0 && len(vr.Mismatched) == 0
+}
+
+// regularFiles lists every regular file under folder, relative to folder
+func regularFiles(folder string) (files map[string]os.FileInfo, err error) {
+	files = make(map[string]os.FileInfo, 32)
+	err = filepath.Walk(folder, func(file string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		strippedFile, err := filepath.Rel(folder, file)
+		if err != nil {
+			return err
+		}
+
+		files[strippedFile] = info
+		return nil
+	})
+	return
+}
+
+// VerifyBackup restores the latest snapshot of a project into a temporary folder, and compares it file by file
+// to the project
+func (fr *ProjectRepository) VerifyBackup(bs BackupService, name, repo string) (result *VerifyResult, err error) {
+	folder := fr.Path(name)
+	if _, err := os.Stat(folder); os.IsNotExist(err) {
+		return nil, ErrNoSuchProject
+	} else if err != nil {
+		return nil, err
+	}
+
+	snapshots, err := fr.Snapshots(bs, name, repo)
+	if err != nil {
+		return
+	}
+
+	if len(snapshots) == 0 {
+		return nil, ErrNoSuchSnapshot
+	}
+
+	result = &VerifyResult{Snapshot: snapshots[len(snapshots)-1]}
+
+	tmpdir, err := ioutil.TempDir("", "proj-verify")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(tmpdir)
+
+	restored := path.Join(tmpdir, name)
+	err = bs.RestoreSnapshot(repo, result.Snapshot, restored)
+	if err != nil {
+		return
+	}
+
+	liveFiles, err := regularFiles(folder)
+	if err != nil {
+		return
+	}
+
+	backupFiles, err := regularFiles(restored)
+	if err != nil {
+		return
+	}
+
+	for file, info := range liveFiles {
+		changed := info.ModTime().After(result.Snapshot.Time)
+
+		if _, exists := backupFiles[file]; !exists {
+			if changed {
+				result.Changed = append(result.Changed, file)
+			} else {
+				result.Missing = append(result.Missing, file)
+			}
+			continue
+		}
+		delete(backupFiles, file)
+
+		liveHash, err := hashFile(path.Join(folder, file))
+		if err != nil {
+			return nil, err
+		}
+
+		backupHash, err := hashFile(path.Join(restored, file))
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case liveHash == backupHash:
+			result.Matched++
+		case changed:
+			result.Changed = append(result.Changed, file)
+		default:
+			result.Mismatched = append(result.Mismatched, file)
+		}
+	}
+
+	for file := range backupFiles {
+		result.Extra = append(result.Extra, file)
+	}
+
+	sort.Strings(result.Missing)
+	sort.Strings(result.Extra)
+	sort.Strings(result.Mismatched)
+	sort.Strings(result.Changed)
+	return
+}